@@ -3,6 +3,7 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"strings"
 
 	"github.com/shanehowearth/interpreter/token"
@@ -12,6 +13,7 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position
 }
 
 // Statement - statements do not produce values
@@ -43,6 +45,14 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+// Pos -
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 // String -
 func (p *Program) String() string {
 	var out bytes.Buffer
@@ -68,6 +78,11 @@ func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
 
+// Pos -
+func (ls *LetStatement) Pos() token.Position {
+	return ls.Token.Pos()
+}
+
 // String -
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
@@ -87,6 +102,13 @@ func (ls *LetStatement) String() string {
 type Identifier struct {
 	Token token.Token // the token.IDENT token
 	Value string
+
+	// Set by the resolver for a local variable, which is held in slot Slot
+	// of the frame Depth frames out from the one the identifier is in.
+	// Globals and builtins are found by name.
+	Local bool
+	Depth int
+	Slot  int
 }
 
 func (i *Identifier) expressionNode() {}
@@ -96,6 +118,11 @@ func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+// Pos -
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos()
+}
+
 // String -
 func (i *Identifier) String() string {
 	return i.Value
@@ -114,6 +141,11 @@ func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
 
+// Pos -
+func (rs *ReturnStatement) Pos() token.Position {
+	return rs.Token.Pos()
+}
+
 // String -
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
@@ -127,6 +159,32 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// ThrowStatement -
+type ThrowStatement struct {
+	Token token.Token // the 'throw' token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode() {}
+
+// TokenLiteral -
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+
+// Pos -
+func (ts *ThrowStatement) Pos() token.Position { return ts.Token.Pos() }
+
+// String -
+func (ts *ThrowStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ts.TokenLiteral() + " ")
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
 // ExpressionStatement -
 type ExpressionStatement struct {
 	Token      token.Token // the first token of the expression
@@ -140,6 +198,11 @@ func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
 
+// Pos -
+func (es *ExpressionStatement) Pos() token.Position {
+	return es.Token.Pos()
+}
+
 // String -
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
@@ -159,6 +222,11 @@ func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
 
+// Pos -
+func (il *IntegerLiteral) Pos() token.Position {
+	return il.Token.Pos()
+}
+
 func (il *IntegerLiteral) expressionNode() {}
 
 // String -
@@ -166,6 +234,52 @@ func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+// BigIntegerLiteral - an integer literal too large for an IntegerLiteral
+type BigIntegerLiteral struct {
+	Token token.Token
+	Value *big.Int
+}
+
+// TokenLiteral -
+func (bl *BigIntegerLiteral) TokenLiteral() string {
+	return bl.Token.Literal
+}
+
+// Pos -
+func (bl *BigIntegerLiteral) Pos() token.Position {
+	return bl.Token.Pos()
+}
+
+func (bl *BigIntegerLiteral) expressionNode() {}
+
+// String -
+func (bl *BigIntegerLiteral) String() string {
+	return bl.Token.Literal
+}
+
+// FloatLiteral -
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+// TokenLiteral -
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+
+// Pos -
+func (fl *FloatLiteral) Pos() token.Position {
+	return fl.Token.Pos()
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+
+// String -
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
 // PrefixExpression -
 type PrefixExpression struct {
 	Token    token.Token // The prefix token, e.g. !
@@ -176,6 +290,9 @@ type PrefixExpression struct {
 // TokenLiteral -
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
 
+// Pos -
+func (pe *PrefixExpression) Pos() token.Position { return pe.Token.Pos() }
+
 func (pe *PrefixExpression) expressionNode() {}
 
 // String -
@@ -202,6 +319,9 @@ func (oe *InfixExpression) expressionNode() {}
 // TokenLiteral -
 func (oe *InfixExpression) TokenLiteral() string { return oe.Token.Literal }
 
+// Pos -
+func (oe *InfixExpression) Pos() token.Position { return oe.Token.Pos() }
+
 // String -
 func (oe *InfixExpression) String() string {
 	var out bytes.Buffer
@@ -215,6 +335,36 @@ func (oe *InfixExpression) String() string {
 	return out.String()
 }
 
+// AssignExpression - assigns to an existing binding, Operator is "=" or a
+// compound operator such as "+="
+type AssignExpression struct {
+	Token    token.Token // The operator token, eg. +=
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+
+// TokenLiteral -
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+
+// Pos -
+func (ae *AssignExpression) Pos() token.Position { return ae.Token.Pos() }
+
+// String -
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
 // Boolean -
 type Boolean struct {
 	Token token.Token
@@ -226,6 +376,9 @@ func (b *Boolean) expressionNode() {}
 // TokenLiteral -
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 
+// Pos -
+func (b *Boolean) Pos() token.Position { return b.Token.Pos() }
+
 // String -
 func (b *Boolean) String() string { return b.Token.Literal }
 
@@ -242,6 +395,9 @@ func (ie *IfExpression) expressionNode() {}
 // TokenLiteral -
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
 
+// Pos -
+func (ie *IfExpression) Pos() token.Position { return ie.Token.Pos() }
+
 // String -
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
@@ -258,6 +414,43 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// TryExpression - Catch and Finally are nil when they are left out, at least
+// one of them is given
+type TryExpression struct {
+	Token      token.Token // The 'try' token
+	Block      *BlockStatement
+	CatchParam *Identifier
+	Catch      *BlockStatement
+	Finally    *BlockStatement
+	CatchSlots int // the size of the frame of the catch block, set by the resolver
+}
+
+func (te *TryExpression) expressionNode() {}
+
+// TokenLiteral -
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+
+// Pos -
+func (te *TryExpression) Pos() token.Position { return te.Token.Pos() }
+
+// String -
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+
+	if te.Catch != nil {
+		out.WriteString(" catch(" + te.CatchParam.String() + ") ")
+		out.WriteString(te.Catch.String())
+	}
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+	return out.String()
+}
+
 // BlockStatement -
 type BlockStatement struct {
 	Token      token.Token // the { token
@@ -269,6 +462,9 @@ func (bs *BlockStatement) expressionNode() {}
 // TokenLiteral -
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
 
+// Pos -
+func (bs *BlockStatement) Pos() token.Position { return bs.Token.Pos() }
+
 // String -
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
@@ -282,8 +478,10 @@ func (bs *BlockStatement) String() string {
 // FunctionLiteral -
 type FunctionLiteral struct {
 	Token      token.Token // the { token
+	Name       string      // the name the function is bound to by let, if any
 	Parameters []*Identifier
 	Body       *BlockStatement
+	Slots      int // the size of the frame of a call, set by the resolver
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
@@ -291,6 +489,9 @@ func (fl *FunctionLiteral) expressionNode() {}
 // TokenLiteral -
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
 
+// Pos -
+func (fl *FunctionLiteral) Pos() token.Position { return fl.Token.Pos() }
+
 // String -
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
@@ -320,6 +521,9 @@ func (ce *CallExpression) expressionNode() {}
 // TokenLiteral -
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
 
+// Pos -
+func (ce *CallExpression) Pos() token.Position { return ce.Token.Pos() }
+
 // String -
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
@@ -345,9 +549,45 @@ func (sl *StringLiteral) expressionNode() {}
 // TokenLiteral -
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 
+// Pos -
+func (sl *StringLiteral) Pos() token.Position { return sl.Token.Pos() }
+
 // String -
 func (sl *StringLiteral) String() string { return sl.Token.Literal }
 
+// InterpolatedString - a string with embedded expressions, e.g. "a ${x} b"
+// Parts alternate between *StringLiteral text and the embedded expressions
+type InterpolatedString struct {
+	Token token.Token // the token.INTERP_START token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode() {}
+
+// TokenLiteral -
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+
+// Pos -
+func (is *InterpolatedString) Pos() token.Position { return is.Token.Pos() }
+
+// String -
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("\"")
+	for _, part := range is.Parts {
+		if text, ok := part.(*StringLiteral); ok {
+			out.WriteString(text.Value)
+			continue
+		}
+		out.WriteString("${")
+		out.WriteString(part.String())
+		out.WriteString("}")
+	}
+	out.WriteString("\"")
+	return out.String()
+}
+
 // ArrayLiteral -
 type ArrayLiteral struct {
 	Token    token.Token // The '[' token
@@ -359,6 +599,9 @@ func (al *ArrayLiteral) expressionNode() {}
 // TokenLiteral -
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
 
+// Pos -
+func (al *ArrayLiteral) Pos() token.Position { return al.Token.Pos() }
+
 // String -
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
@@ -386,6 +629,9 @@ func (ie *IndexExpression) expressionNode() {}
 // TokenLiteral -
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
 
+// Pos -
+func (ie *IndexExpression) Pos() token.Position { return ie.Token.Pos() }
+
 // String -
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
@@ -401,7 +647,13 @@ func (ie *IndexExpression) String() string {
 // HashLiteral -
 type HashLiteral struct {
 	Token token.Token // the '{' token
-	Pairs map[Expression]Expression
+	Pairs []HashPair  // in the order they are written
+}
+
+// HashPair - a key and its value in a hash literal
+type HashPair struct {
+	Key   Expression
+	Value Expression
 }
 
 func (hl *HashLiteral) expressionNode() {}
@@ -409,16 +661,234 @@ func (hl *HashLiteral) expressionNode() {}
 // TokenLiteral -
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
 
+// Pos -
+func (hl *HashLiteral) Pos() token.Position { return hl.Token.Pos() }
+
 // String -
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
 	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(pairs, ", "))
 	out.WriteString(")")
 	return out.String()
 }
+
+// WhileStatement -
+type WhileStatement struct {
+	Token     token.Token // the 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+
+// TokenLiteral -
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+
+// Pos -
+func (ws *WhileStatement) Pos() token.Position { return ws.Token.Pos() }
+
+// String -
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
+// ForStatement - a C style for loop, Init, Condition and Update are nil when
+// they are left out
+type ForStatement struct {
+	Token     token.Token // the 'for' token
+	Init      Statement
+	Condition Expression
+	Update    Expression
+	Body      *BlockStatement
+	Slots     int // the size of the frame of the loop, set by the resolver
+}
+
+func (fs *ForStatement) statementNode() {}
+
+// TokenLiteral -
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+
+// Pos -
+func (fs *ForStatement) Pos() token.Position { return fs.Token.Pos() }
+
+// String -
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for(")
+	if fs.Init != nil {
+		out.WriteString(strings.TrimSuffix(fs.Init.String(), ";"))
+	}
+	out.WriteString("; ")
+	if fs.Condition != nil {
+		out.WriteString(fs.Condition.String())
+	}
+	out.WriteString("; ")
+	if fs.Update != nil {
+		out.WriteString(fs.Update.String())
+	}
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+// ForInStatement - iterates over the elements of an array, the characters of
+// a string or the keys of a hash
+type ForInStatement struct {
+	Token    token.Token // the 'for' token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+	Slots    int // the size of the frame of an iteration, set by the resolver
+}
+
+func (fs *ForInStatement) statementNode() {}
+
+// TokenLiteral -
+func (fs *ForInStatement) TokenLiteral() string { return fs.Token.Literal }
+
+// Pos -
+func (fs *ForInStatement) Pos() token.Position { return fs.Token.Pos() }
+
+// String -
+func (fs *ForInStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for(")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+// BreakStatement -
+type BreakStatement struct {
+	Token token.Token // the 'break' token
+}
+
+func (bs *BreakStatement) statementNode() {}
+
+// TokenLiteral -
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+
+// Pos -
+func (bs *BreakStatement) Pos() token.Position { return bs.Token.Pos() }
+
+// String -
+func (bs *BreakStatement) String() string { return bs.Token.Literal + ";" }
+
+// ContinueStatement -
+type ContinueStatement struct {
+	Token token.Token // the 'continue' token
+}
+
+func (cs *ContinueStatement) statementNode() {}
+
+// TokenLiteral -
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+
+// Pos -
+func (cs *ContinueStatement) Pos() token.Position { return cs.Token.Pos() }
+
+// String -
+func (cs *ContinueStatement) String() string { return cs.Token.Literal + ";" }
+
+// Children - calls visit for each of the nodes directly in node, in source
+// order. The identifiers a node binds, such as the name of a let or the
+// parameters of a function, are part of it rather than nodes in it.
+func Children(node Node, visit func(Node)) {
+	switch node := node.(type) {
+	case *Program:
+		for _, stmt := range node.Statements {
+			visit(stmt)
+		}
+	case *BlockStatement:
+		if node == nil {
+			return
+		}
+		for _, stmt := range node.Statements {
+			visit(stmt)
+		}
+	case *LetStatement:
+		visit(node.Value)
+	case *ExpressionStatement:
+		visit(node.Expression)
+	case *ReturnStatement:
+		visit(node.ReturnValue)
+	case *ThrowStatement:
+		visit(node.Value)
+	case *WhileStatement:
+		visit(node.Condition)
+		visit(node.Body)
+	case *ForStatement:
+		if node.Init != nil {
+			visit(node.Init)
+		}
+		if node.Condition != nil {
+			visit(node.Condition)
+		}
+		if node.Update != nil {
+			visit(node.Update)
+		}
+		visit(node.Body)
+	case *ForInStatement:
+		visit(node.Iterable)
+		visit(node.Body)
+	case *PrefixExpression:
+		visit(node.Right)
+	case *InfixExpression:
+		visit(node.Left)
+		visit(node.Right)
+	case *AssignExpression:
+		visit(node.Target)
+		visit(node.Value)
+	case *IfExpression:
+		visit(node.Condition)
+		visit(node.Consequence)
+		visit(node.Alternative)
+	case *TryExpression:
+		visit(node.Block)
+		visit(node.Catch)
+		visit(node.Finally)
+	case *FunctionLiteral:
+		visit(node.Body)
+	case *CallExpression:
+		visit(node.Function)
+		for _, arg := range node.Arguments {
+			visit(arg)
+		}
+	case *IndexExpression:
+		visit(node.Left)
+		visit(node.Index)
+	case *ArrayLiteral:
+		for _, element := range node.Elements {
+			visit(element)
+		}
+	case *HashLiteral:
+		for _, pair := range node.Pairs {
+			visit(pair.Key)
+			visit(pair.Value)
+		}
+	case *InterpolatedString:
+		for _, part := range node.Parts {
+			visit(part)
+		}
+	}
+}
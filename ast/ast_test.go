@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/shanehowearth/interpreter/token"
@@ -26,3 +27,32 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong, got=%q", program.String())
 	}
 }
+
+func TestChildren(t *testing.T) {
+	ident := func(name string) *Identifier {
+		return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+	}
+	tests := []struct {
+		node     Node
+		expected []string
+	}{
+		{&LetStatement{Name: ident("x"), Value: ident("y")}, []string{"y"}},
+		{&InfixExpression{Left: ident("a"), Operator: "+", Right: ident("b")}, []string{"a", "b"}},
+		{&CallExpression{Function: ident("f"), Arguments: []Expression{ident("a"), ident("b")}}, []string{"f", "a", "b"}},
+		// the parts of a for header that are left out are not visited
+		{&ForStatement{Condition: ident("c"), Body: &BlockStatement{
+			Statements: []Statement{&ExpressionStatement{Expression: ident("b")}},
+		}}, []string{"c", "b"}},
+		{&IndexExpression{Left: ident("a"), Index: ident("i")}, []string{"a", "i"}},
+		{ident("x"), nil},
+	}
+	for _, tt := range tests {
+		var visited []string
+		Children(tt.node, func(child Node) {
+			visited = append(visited, child.String())
+		})
+		if strings.Join(visited, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("wrong children of %q. expected=%q, got=%q", tt.node.String(), tt.expected, visited)
+		}
+	}
+}
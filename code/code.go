@@ -0,0 +1,291 @@
+// Package code - the bytecode instructions the compiler produces and the vm
+// executes
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Instructions -
+type Instructions []byte
+
+// String - the instructions disassembled, one per line
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n",
+			len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+// Opcode -
+type Opcode byte
+
+// nolint: revive
+const (
+	OpConstant Opcode = iota
+	OpPop
+	OpTrue
+	OpFalse
+	OpNull
+	OpNil // the value of a let statement or an empty block, which is no value
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpPow
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+	OpEqual
+	OpNotEqual
+	OpLessThan
+	OpGreaterThan
+	OpLessEqual
+	OpGreaterEqual
+
+	OpMinus
+	OpBang
+	OpBitNot
+
+	OpJump
+	OpJumpNotTruthy
+	OpJumpTruthy
+
+	OpGetGlobal
+	OpSetGlobal
+	OpAssignGlobal
+	OpGetLocal
+	OpSetLocal
+	OpAssignLocal
+	OpGetBuiltin
+	OpPushScope
+	OpPopScope
+
+	OpArray
+	OpHash
+	OpInterpolate
+	OpIndex
+	OpIndexKeep
+	OpSetIndex
+
+	OpClosure
+	OpCall
+	OpTailCall
+	OpReturnValue
+
+	OpStep
+	OpIterate
+	OpIterNext
+
+	OpSetupTry
+	OpPopTry
+	OpCatch
+	OpThrow
+	OpRethrow
+	OpError
+)
+
+// Definition - the name of an opcode and the width in bytes of each of its
+// operands
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	// OpConstant pushes the constant at the index
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+	OpTrue:     {"OpTrue", []int{}},
+	OpFalse:    {"OpFalse", []int{}},
+	OpNull:     {"OpNull", []int{}},
+	OpNil:      {"OpNil", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpMod:          {"OpMod", []int{}},
+	OpPow:          {"OpPow", []int{}},
+	OpBitAnd:       {"OpBitAnd", []int{}},
+	OpBitOr:        {"OpBitOr", []int{}},
+	OpBitXor:       {"OpBitXor", []int{}},
+	OpShiftLeft:    {"OpShiftLeft", []int{}},
+	OpShiftRight:   {"OpShiftRight", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+
+	OpMinus:  {"OpMinus", []int{}},
+	OpBang:   {"OpBang", []int{}},
+	OpBitNot: {"OpBitNot", []int{}},
+
+	// the jumps take the offset of the instruction to continue at, the
+	// conditional ones pop the condition
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJumpTruthy:    {"OpJumpTruthy", []int{2}},
+
+	// OpSetGlobal and OpSetLocal bind a variable declared by let, popping the
+	// value, OpAssignGlobal and OpAssignLocal update one that has been bound,
+	// leaving the value as the result of the assignment. A local is found by
+	// the number of scopes out from the current one and its slot there.
+	OpGetGlobal:    {"OpGetGlobal", []int{2}},
+	OpSetGlobal:    {"OpSetGlobal", []int{2}},
+	OpAssignGlobal: {"OpAssignGlobal", []int{2}},
+	OpGetLocal:     {"OpGetLocal", []int{2, 2}},
+	OpSetLocal:     {"OpSetLocal", []int{2, 2}},
+	OpAssignLocal:  {"OpAssignLocal", []int{2, 2}},
+	// OpGetBuiltin looks up the builtin named by the constant at the index,
+	// for a name that is not bound in any enclosing scope
+	OpGetBuiltin: {"OpGetBuiltin", []int{2}},
+	// OpPushScope enters a block scope with the number of slots, such as the
+	// body of a for loop
+	OpPushScope: {"OpPushScope", []int{2}},
+	OpPopScope:  {"OpPopScope", []int{}},
+
+	// OpArray and OpInterpolate take the number of values, OpHash the number
+	// of keys and values
+	OpArray:       {"OpArray", []int{2}},
+	OpHash:        {"OpHash", []int{2}},
+	OpInterpolate: {"OpInterpolate", []int{2}},
+	OpIndex:       {"OpIndex", []int{}},
+	// OpIndexKeep pushes the element at the container and index on top,
+	// leaving them below it, for a compound index assignment
+	OpIndexKeep: {"OpIndexKeep", []int{}},
+	OpSetIndex:  {"OpSetIndex", []int{}},
+
+	// OpClosure takes the index of the compiled function in the constants,
+	// OpCall and OpTailCall the number of arguments
+	OpClosure:     {"OpClosure", []int{2}},
+	OpCall:        {"OpCall", []int{1}},
+	OpTailCall:    {"OpTailCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+
+	// OpStep counts a loop iteration. OpIterate turns the iterable on top
+	// into an iterator, OpIterNext pushes the next value of the iterator on
+	// top, or jumps to the offset once it is exhausted.
+	OpStep:     {"OpStep", []int{}},
+	OpIterate:  {"OpIterate", []int{}},
+	OpIterNext: {"OpIterNext", []int{2}},
+
+	// OpSetupTry installs a handler that continues at the offset with the
+	// error pushed, when one is raised before the matching OpPopTry. OpCatch
+	// turns the error into the value bound by catch, OpRethrow raises it
+	// again. OpError raises an error with the message at the index.
+	OpSetupTry: {"OpSetupTry", []int{2}},
+	OpPopTry:   {"OpPopTry", []int{}},
+	OpCatch:    {"OpCatch", []int{}},
+	OpThrow:    {"OpThrow", []int{}},
+	OpRethrow:  {"OpRethrow", []int{}},
+	OpError:    {"OpError", []int{2}},
+}
+
+// Lookup -
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// Make - encodes an instruction
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands - decodes the operands of an instruction, returning them and
+// the number of bytes they took
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+// ReadUint16 -
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+// ReadUint8 -
+func ReadUint8(ins Instructions) uint8 { return uint8(ins[0]) }
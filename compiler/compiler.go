@@ -0,0 +1,646 @@
+// Package compiler - compiles a program to bytecode for the vm
+package compiler
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/shanehowearth/interpreter/ast"
+	"github.com/shanehowearth/interpreter/code"
+	"github.com/shanehowearth/interpreter/diagnostic"
+	"github.com/shanehowearth/interpreter/object"
+	"github.com/shanehowearth/interpreter/resolver"
+	"github.com/shanehowearth/interpreter/token"
+)
+
+var prefixOperators = map[string]code.Opcode{
+	"-": code.OpMinus,
+	"!": code.OpBang,
+	"~": code.OpBitNot,
+}
+
+var infixOperators = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"**": code.OpPow,
+	"&":  code.OpBitAnd,
+	"|":  code.OpBitOr,
+	"^":  code.OpBitXor,
+	"<<": code.OpShiftLeft,
+	">>": code.OpShiftRight,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	"<":  code.OpLessThan,
+	">":  code.OpGreaterThan,
+	"<=": code.OpLessEqual,
+	">=": code.OpGreaterEqual,
+}
+
+// Compiler -
+type Compiler struct {
+	constants   []object.Object
+	names       map[string]int // the constants holding the names of builtins
+	symbolTable *SymbolTable
+
+	scopes     []*CompilationScope
+	scopeIndex int
+
+	err error // the first operand found too large for its instruction
+}
+
+// CompilationScope - the instructions of the program or a function being
+// compiled
+type CompilationScope struct {
+	instructions code.Instructions
+	positions    map[int]token.Position
+	names        map[int]string
+
+	blocks int         // the block scopes the instructions being compiled are in
+	loops  []*loop     // the loops the instructions are in, innermost last
+	tries  []*tryBlock // the try blocks with a handler installed, innermost last
+}
+
+// loop is where break and continue jump to, they leave the block scopes and
+// try blocks entered inside the loop first
+type loop struct {
+	tries          int
+	breakBlocks    int
+	continueBlocks int
+	breaks         []int
+	continues      []int
+}
+
+// tryBlock is a try block, or a catch block with a finally block, the finally
+// block is compiled again wherever a jump leaves the try block
+type tryBlock struct {
+	finally     *ast.BlockStatement
+	blocks      int
+	symbolTable *SymbolTable
+}
+
+// Bytecode - a compiled program
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	Positions    map[int]token.Position
+	Names        map[int]string
+}
+
+// New -
+func New() *Compiler {
+	return &Compiler{
+		names:       make(map[string]int),
+		symbolTable: NewSymbolTable(),
+		scopes:      []*CompilationScope{newCompilationScope()},
+	}
+}
+
+func newCompilationScope() *CompilationScope {
+	return &CompilationScope{
+		positions: make(map[int]token.Position),
+		names:     make(map[int]string),
+	}
+}
+
+// Bytecode -
+func (c *Compiler) Bytecode() *Bytecode {
+	scope := c.scope()
+	return &Bytecode{
+		Instructions: scope.instructions,
+		Constants:    c.constants,
+		Positions:    scope.positions,
+		Names:        scope.names,
+	}
+}
+
+// Compile -
+func (c *Compiler) Compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		// a program the resolver reports an error in is not compiled, the
+		// error is returned as a diagnostic.Diagnostic
+		for _, d := range resolver.Resolve(node) {
+			if d.Severity == diagnostic.Error {
+				return d
+			}
+		}
+		for _, stmt := range node.Statements {
+			declare(c.symbolTable, stmt)
+		}
+		if err := c.compileStatements(node.Statements, false); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+		if c.err != nil {
+			return c.err
+		}
+		return c.checkSize()
+
+	// Statements
+	case *ast.ExpressionStatement:
+		return c.Compile(node.Expression)
+	case *ast.BlockStatement:
+		return c.compileStatements(node.Statements, false)
+	case *ast.LetStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.setSymbol(c.symbolTable.Define(node.Name.Value))
+	case *ast.ReturnStatement:
+		return c.compileReturn(node)
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emitAt(node, code.OpThrow)
+	case *ast.WhileStatement:
+		return c.compileWhile(node)
+	case *ast.ForStatement:
+		return c.compileFor(node)
+	case *ast.ForInStatement:
+		return c.compileForIn(node)
+	case *ast.BreakStatement:
+		l := c.scope().loops[len(c.scope().loops)-1]
+		if err := c.leave(l.tries, l.breakBlocks); err != nil {
+			return err
+		}
+		l.breaks = append(l.breaks, c.emit(code.OpJump, 9999))
+	case *ast.ContinueStatement:
+		l := c.scope().loops[len(c.scope().loops)-1]
+		if err := c.leave(l.tries, l.continueBlocks); err != nil {
+			return err
+		}
+		l.continues = append(l.continues, c.emit(code.OpJump, 9999))
+
+	// Expressions
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+	case *ast.BigIntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.BigInt{Value: node.Value}))
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.PrefixExpression:
+		op, ok := prefixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emitAt(node, op)
+	case *ast.InfixExpression:
+		return c.compileInfix(node)
+	case *ast.AssignExpression:
+		return c.compileAssign(node)
+	case *ast.IfExpression:
+		return c.compileIf(node, false)
+	case *ast.TryExpression:
+		return c.compileTry(node)
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.resolveIdentifier(node)
+		if !ok {
+			c.emitAt(node, code.OpGetBuiltin, c.nameConstant(node.Value))
+			return nil
+		}
+		c.loadSymbol(symbol, node)
+	case *ast.FunctionLiteral:
+		return c.compileFunction(node)
+	case *ast.CallExpression:
+		return c.compileCall(node, code.OpCall)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emitAt(node, code.OpHash, len(node.Pairs)*2)
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			if err := c.Compile(part); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpInterpolate, len(node.Parts))
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emitAt(node, code.OpIndex)
+	default:
+		return fmt.Errorf("cannot compile %T", node)
+	}
+	return nil
+}
+
+// compileStatements leaves the value of the last statement, as the evaluator
+// does for a block. When tail is set a call that is the last expression is a
+// tail call.
+func (c *Compiler) compileStatements(stmts []ast.Statement, tail bool) error {
+	if len(stmts) == 0 {
+		c.emit(code.OpNil)
+		return nil
+	}
+
+	for idx, stmt := range stmts {
+		last := idx == len(stmts)-1
+		var err error
+		if exp, ok := stmt.(*ast.ExpressionStatement); ok && last && tail {
+			err = c.compileTail(exp.Expression)
+		} else {
+			err = c.Compile(stmt)
+		}
+		if err != nil {
+			return err
+		}
+
+		value := leavesValue(stmt)
+		switch {
+		case value && !last:
+			c.emit(code.OpPop)
+		case !value && last:
+			c.emit(code.OpNil)
+		}
+	}
+	return nil
+}
+
+// leavesValue reports whether stmt leaves a value on the stack, loops leave
+// null as they evaluate to it
+func leavesValue(stmt ast.Statement) bool {
+	switch stmt.(type) {
+	case *ast.ExpressionStatement, *ast.WhileStatement, *ast.ForStatement, *ast.ForInStatement:
+		return true
+	default:
+		return false
+	}
+}
+
+// compileTail compiles an expression in tail position
+func (c *Compiler) compileTail(node ast.Expression) error {
+	switch node := node.(type) {
+	case *ast.CallExpression:
+		return c.compileCall(node, code.OpTailCall)
+	case *ast.IfExpression:
+		return c.compileIf(node, true)
+	default:
+		return c.Compile(node)
+	}
+}
+
+// compileReturn compiles a return, a returned call is a tail call unless it
+// is made in a try block, where its errors are caught
+func (c *Compiler) compileReturn(node *ast.ReturnStatement) error {
+	var err error
+	if c.scopeIndex > 0 && len(c.scope().tries) == 0 {
+		err = c.compileTail(node.ReturnValue)
+	} else {
+		err = c.Compile(node.ReturnValue)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := c.leave(0, c.scope().blocks); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+	return nil
+}
+
+func (c *Compiler) compileInfix(node *ast.InfixExpression) error {
+	if node.Operator == "&&" || node.Operator == "||" {
+		return c.compileLogical(node)
+	}
+
+	op, ok := infixOperators[node.Operator]
+	if !ok {
+		return fmt.Errorf("unknown operator %s", node.Operator)
+	}
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	c.emitAt(node, op)
+	return nil
+}
+
+// compileLogical compiles && and ||, the right operand is jumped over when the
+// left one decides the result
+func (c *Compiler) compileLogical(node *ast.InfixExpression) error {
+	jump, decided, undecided := code.OpJumpNotTruthy, code.OpFalse, code.OpTrue
+	if node.Operator == "||" {
+		jump, decided, undecided = code.OpJumpTruthy, code.OpTrue, code.OpFalse
+	}
+
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+	leftPos := c.emit(jump, 9999)
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	rightPos := c.emit(jump, 9999)
+	c.emit(undecided)
+	endPos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(leftPos, len(c.currentInstructions()))
+	c.changeOperand(rightPos, len(c.currentInstructions()))
+	c.emit(decided)
+	c.changeOperand(endPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileAssign compiles an assignment, the value of which is the value
+// assigned. A compound assignment applies its operator to the current value
+// of the target first.
+func (c *Compiler) compileAssign(node *ast.AssignExpression) error {
+	operator := strings.TrimSuffix(node.Operator, "=")
+	op, compound := infixOperators[operator]
+
+	if target, ok := node.Target.(*ast.IndexExpression); ok {
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+		if compound {
+			c.emitAt(target, code.OpIndexKeep)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if compound {
+			c.emitAt(node, op)
+		}
+		c.emitAt(target, code.OpSetIndex)
+		return nil
+	}
+
+	ident := node.Target.(*ast.Identifier)
+	symbol, ok := c.symbolTable.resolveIdentifier(ident)
+	if !ok {
+		message := &object.String{Value: "assignment to undeclared variable: " + ident.Value}
+		c.emitAt(ident, code.OpError, c.addConstant(message))
+		return nil
+	}
+
+	if compound {
+		c.loadSymbol(symbol, ident)
+	}
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+	if compound {
+		c.emitAt(node, op)
+	}
+
+	var pos int
+	switch symbol.Scope {
+	case GlobalScope:
+		pos = c.emitAt(ident, code.OpAssignGlobal, symbol.Index)
+	case LocalScope:
+		pos = c.emitAt(ident, code.OpAssignLocal, symbol.Depth, symbol.Index)
+	}
+	c.scope().names[pos] = ident.Value
+	return nil
+}
+
+func (c *Compiler) compileIf(node *ast.IfExpression, tail bool) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+	if err := c.compileStatements(node.Consequence.Statements, tail); err != nil {
+		return err
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileStatements(node.Alternative.Statements, tail); err != nil {
+		return err
+	}
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileCall(node *ast.CallExpression, op code.Opcode) error {
+	if len(node.Arguments) > math.MaxUint8 {
+		return fmt.Errorf("too many arguments: %d, at most %d", len(node.Arguments), math.MaxUint8)
+	}
+	if err := c.Compile(node.Function); err != nil {
+		return err
+	}
+	for _, arg := range node.Arguments {
+		if err := c.Compile(arg); err != nil {
+			return err
+		}
+	}
+	c.emitAt(node, op, len(node.Arguments))
+	return nil
+}
+
+// compileFunction compiles the body of a function literal, the parameters
+// are the first slots of its scope and the variables it declares follow them
+func (c *Compiler) compileFunction(node *ast.FunctionLiteral) error {
+	c.enterScope()
+
+	for _, p := range node.Parameters {
+		c.symbolTable.DefineParameter(p.Value)
+	}
+	declare(c.symbolTable, node.Body)
+
+	if err := c.compileStatements(node.Body.Statements, true); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+	if err := c.checkSize(); err != nil {
+		return err
+	}
+
+	numLocals := c.symbolTable.numDefinitions
+	scope := c.leaveScope()
+
+	fn := &object.CompiledFunction{
+		Instructions:  scope.instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Name:          node.Name,
+		Positions:     scope.positions,
+		Names:         scope.names,
+	}
+	c.emit(code.OpClosure, c.addConstant(fn))
+	return nil
+}
+
+func (c *Compiler) loadSymbol(symbol Symbol, node *ast.Identifier) {
+	var pos int
+	switch symbol.Scope {
+	case GlobalScope:
+		pos = c.emitAt(node, code.OpGetGlobal, symbol.Index)
+	case LocalScope:
+		pos = c.emitAt(node, code.OpGetLocal, symbol.Depth, symbol.Index)
+	}
+	c.scope().names[pos] = node.Value
+}
+
+// setSymbol binds symbol, defined in the current scope, to the value on top
+func (c *Compiler) setSymbol(symbol Symbol) {
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, symbol.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, 0, symbol.Index)
+	}
+}
+
+// nameConstant is the constant holding name, which is only added once
+func (c *Compiler) nameConstant(name string) int {
+	if idx, ok := c.names[name]; ok {
+		return idx
+	}
+	idx := c.addConstant(&object.String{Value: name})
+	c.names[name] = idx
+	return idx
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	if len(c.constants) > math.MaxUint16+1 && c.err == nil {
+		c.err = fmt.Errorf("too many constants: %d, at most %d", len(c.constants), math.MaxUint16+1)
+	}
+	return len(c.constants) - 1
+}
+
+// emit appends an instruction, an operand too large for it, such as the
+// index of a constant or a variable, is reported once the program has been
+// compiled
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	c.checkOperands(op, operands)
+	ins := code.Make(op, operands...)
+	scope := c.scope()
+	pos := len(scope.instructions)
+	scope.instructions = append(scope.instructions, ins...)
+	return pos
+}
+
+// emitAt emits an instruction that can raise an error, recording where node
+// is in the source for the error
+func (c *Compiler) emitAt(node ast.Node, op code.Opcode, operands ...int) int {
+	pos := c.emit(op, operands...)
+	c.scope().positions[pos] = node.Pos()
+	return pos
+}
+
+// changeOperand replaces the operand of the instruction at opPos, such as the
+// target of a jump once it is known
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	ins := c.currentInstructions()
+	op := code.Opcode(ins[opPos])
+	copy(ins[opPos:], code.Make(op, operand))
+}
+
+// checkOperands records the first operand of op too large for its width
+func (c *Compiler) checkOperands(op code.Opcode, operands []int) {
+	def, err := code.Lookup(byte(op))
+	if err != nil || c.err != nil {
+		return
+	}
+	for i, operand := range operands {
+		max := math.MaxUint16
+		if i < len(def.OperandWidths) && def.OperandWidths[i] == 1 {
+			max = math.MaxUint8
+		}
+		if operand > max {
+			c.err = fmt.Errorf("operand of %s too large: %d, at most %d", def.Name, operand, max)
+			return
+		}
+	}
+}
+
+// checkSize reports instructions that jumps, with two byte operands, cannot
+// reach the end of
+func (c *Compiler) checkSize() error {
+	if size := len(c.currentInstructions()); size > math.MaxUint16 {
+		return fmt.Errorf("too many instructions: %d bytes, at most %d", size, math.MaxUint16)
+	}
+	return nil
+}
+
+func (c *Compiler) scope() *CompilationScope {
+	return c.scopes[c.scopeIndex]
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scope().instructions
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, newCompilationScope())
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() *CompilationScope {
+	scope := c.scope()
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+	return scope
+}
+
+// enterBlock enters a block scope with the variables of table, the returned
+// position is that of the instruction that creates it, the number of slots
+// is only known once the block has been compiled
+func (c *Compiler) enterBlock(table *SymbolTable) int {
+	pos := c.emit(code.OpPushScope, 9999)
+	c.symbolTable = table
+	c.scope().blocks++
+	return pos
+}
+
+func (c *Compiler) leaveBlock(pushPos int) {
+	c.emit(code.OpPopScope)
+	c.scope().blocks--
+	c.changeOperand(pushPos, c.symbolTable.numDefinitions)
+	c.symbolTable = c.symbolTable.Outer
+}
+
+// popBlocks leaves the block scopes entered since there were blocks of them
+func (c *Compiler) popBlocks(blocks int) {
+	scope := c.scope()
+	for scope.blocks > blocks {
+		c.emit(code.OpPopScope)
+		scope.blocks--
+	}
+}
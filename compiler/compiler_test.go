@@ -0,0 +1,252 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shanehowearth/interpreter/code"
+	"github.com/shanehowearth/interpreter/lexer"
+	"github.com/shanehowearth/interpreter/object"
+	"github.com/shanehowearth/interpreter/parser"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+}
+
+func TestCompile(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "1; 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "if (true) { 10 }; 3333",
+			expectedConstants: []interface{}{10, 3333},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 11),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "let one = 1; let two = one; two",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "let f = fn(a) { let b = a; b }; f(1)",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0, 0),
+					code.Make(code.OpSetLocal, 0, 1),
+					code.Make(code.OpGetLocal, 0, 1),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "len([1, 2])",
+			expectedConstants: []interface{}{"len", 1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "let a = [1]; a[0] += 2",
+			expectedConstants: []interface{}{1, 0, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpIndexKeep),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSetIndex),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "while (x) { }",
+			expectedConstants: []interface{}{"x"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpStep),
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpJumpNotTruthy, 12),
+				code.Make(code.OpNil),
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("%q: compiler error: %s", tt.input, err)
+		}
+		bytecode := compiler.Bytecode()
+		testInstructions(t, tt.input, tt.expectedInstructions, bytecode.Instructions)
+		testConstants(t, tt.input, tt.expectedConstants, bytecode.Constants)
+	}
+}
+
+func testInstructions(t *testing.T, input string, expected []code.Instructions, actual code.Instructions) {
+	t.Helper()
+	var concatted code.Instructions
+	for _, ins := range expected {
+		concatted = append(concatted, ins...)
+	}
+	if actual.String() != concatted.String() {
+		t.Errorf("%q: wrong instructions.\nwant=\n%sgot=\n%s", input, concatted, actual)
+	}
+}
+
+func testConstants(t *testing.T, input string, expected []interface{}, actual []object.Object) {
+	t.Helper()
+	if len(expected) != len(actual) {
+		t.Errorf("%q: wrong number of constants. want=%d, got=%d", input, len(expected), len(actual))
+		return
+	}
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if !ok || integer.Value != int64(constant) {
+				t.Errorf("%q: constant %d wrong. want=%d, got=%s", input, i, constant, actual[i].Inspect())
+			}
+		case string:
+			str, ok := actual[i].(*object.String)
+			if !ok || str.Value != constant {
+				t.Errorf("%q: constant %d wrong. want=%q, got=%s", input, i, constant, actual[i].Inspect())
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				t.Errorf("%q: constant %d not a function. got=%T", input, i, actual[i])
+				continue
+			}
+			testInstructions(t, input, constant, fn.Instructions)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	input := "f(" + strings.Repeat("1, ", 255) + "1)"
+	program := parser.New(lexer.New(input)).ParseProgram()
+	err := New().Compile(program)
+	if err == nil || !strings.Contains(err.Error(), "too many arguments") {
+		t.Errorf("expected too many arguments error. got=%v", err)
+	}
+
+	// operands are two bytes, larger indexes are reported rather than cut
+	// short. Each function stays well within the size of its instructions.
+	var functions strings.Builder
+	for i := 0; i < 100; i++ {
+		functions.WriteString("fn() { [" + strings.Repeat("1, ", 700) + "1] };")
+	}
+	var globals strings.Builder
+	for i := 0; i <= 65536; i++ {
+		name := []byte("aaaa")
+		for j, n := len(name)-1, i; n > 0; j, n = j-1, n/26 {
+			name[j] += byte(n % 26)
+		}
+		globals.WriteString("let " + string(name) + " = true;")
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{functions.String(), "too many constants: 65537, at most 65536"},
+		{globals.String(), "operand of OpSetGlobal too large: 65536, at most 65535"},
+		{"[" + strings.Repeat("true, ", 65536) + "true]", "operand of OpArray too large: 65537, at most 65535"},
+	}
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		err := New().Compile(program)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%v", tt.expected, err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	local := NewEnclosedSymbolTable(global)
+	b := local.DefineParameter("b")
+	block := NewEnclosedSymbolTable(local)
+	c := block.Define("c")
+
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: a.Index, Depth: 2},
+		"b": {Name: "b", Scope: LocalScope, Index: b.Index, Depth: 1},
+		"c": {Name: "c", Scope: LocalScope, Index: c.Index},
+	}
+	for name, sym := range expected {
+		result, ok := block.Resolve(name)
+		if !ok {
+			t.Errorf("name %s not resolvable", name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", name, sym, result)
+		}
+	}
+	if _, ok := block.Resolve("d"); ok {
+		t.Errorf("name d resolved, it is not defined")
+	}
+
+	if again := block.Define("c"); again != c {
+		t.Errorf("redefining c in the same scope moved it. expected=%+v, got=%+v", c, again)
+	}
+}
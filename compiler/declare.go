@@ -0,0 +1,25 @@
+package compiler
+
+import "github.com/shanehowearth/interpreter/ast"
+
+// declare defines the variables bound by the let statements in node in the
+// scope of table, before any of them is compiled, so that a function can use
+// a variable bound after it is created, as in mutual recursion. The bodies of
+// functions, for loops and catch blocks have scopes of their own and are
+// declared when they are compiled.
+func declare(table *SymbolTable, node ast.Node) {
+	switch node := node.(type) {
+	case *ast.FunctionLiteral, *ast.ForStatement:
+		return
+	case *ast.ForInStatement:
+		declare(table, node.Iterable)
+		return
+	case *ast.TryExpression:
+		declare(table, node.Block)
+		declare(table, node.Finally)
+		return
+	case *ast.LetStatement:
+		table.Define(node.Name.Value)
+	}
+	ast.Children(node, func(child ast.Node) { declare(table, child) })
+}
@@ -0,0 +1,158 @@
+package compiler
+
+import (
+	"github.com/shanehowearth/interpreter/ast"
+	"github.com/shanehowearth/interpreter/code"
+)
+
+// enterLoop starts a loop, break leaves the block scopes entered since there
+// were breakBlocks of them and continue those since there were continueBlocks
+func (c *Compiler) enterLoop(breakBlocks, continueBlocks int) {
+	scope := c.scope()
+	scope.loops = append(scope.loops, &loop{
+		tries:          len(scope.tries),
+		breakBlocks:    breakBlocks,
+		continueBlocks: continueBlocks,
+	})
+}
+
+// leaveLoop ends the innermost loop, pointing its break and continue jumps at
+// their targets
+func (c *Compiler) leaveLoop(breakPos, continuePos int) {
+	scope := c.scope()
+	l := scope.loops[len(scope.loops)-1]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, breakPos)
+	}
+	for _, pos := range l.continues {
+		c.changeOperand(pos, continuePos)
+	}
+}
+
+func (c *Compiler) compileWhile(node *ast.WhileStatement) error {
+	startPos := len(c.currentInstructions())
+	c.emit(code.OpStep)
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	exitPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	blocks := c.scope().blocks
+	c.enterLoop(blocks, blocks)
+	if err := c.compileBody(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, startPos)
+
+	c.changeOperand(exitPos, len(c.currentInstructions()))
+	c.leaveLoop(len(c.currentInstructions()), startPos)
+	c.emit(code.OpNull)
+	return nil
+}
+
+// compileFor compiles a C style for loop, which has a block scope for the
+// variables declared by its init and its body
+func (c *Compiler) compileFor(node *ast.ForStatement) error {
+	table := NewEnclosedSymbolTable(c.symbolTable)
+	if node.Init != nil {
+		declare(table, node.Init)
+	}
+	declare(table, node.Body)
+	pushPos := c.enterBlock(table)
+
+	if node.Init != nil {
+		if err := c.Compile(node.Init); err != nil {
+			return err
+		}
+		if leavesValue(node.Init) {
+			c.emit(code.OpPop)
+		}
+	}
+
+	startPos := len(c.currentInstructions())
+	c.emit(code.OpStep)
+	exitPos := -1
+	if node.Condition != nil {
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+		exitPos = c.emit(code.OpJumpNotTruthy, 9999)
+	}
+
+	blocks := c.scope().blocks
+	c.enterLoop(blocks, blocks)
+	if err := c.compileBody(node.Body); err != nil {
+		return err
+	}
+
+	continuePos := len(c.currentInstructions())
+	if node.Update != nil {
+		if err := c.Compile(node.Update); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	}
+	c.emit(code.OpJump, startPos)
+
+	if exitPos >= 0 {
+		c.changeOperand(exitPos, len(c.currentInstructions()))
+	}
+	c.leaveLoop(len(c.currentInstructions()), continuePos)
+	c.leaveBlock(pushPos)
+	c.emit(code.OpNull)
+	return nil
+}
+
+// compileForIn compiles a for in loop, which binds its variable in a new
+// block scope for every iteration. The iterator is kept in a slot of the
+// enclosing scope.
+func (c *Compiler) compileForIn(node *ast.ForInStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emitAt(node.Iterable, code.OpIterate)
+	iterator := c.symbolTable.defineHidden()
+	c.setSymbol(iterator)
+
+	startPos := len(c.currentInstructions())
+	switch iterator.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, iterator.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, 0, iterator.Index)
+	}
+	exitPos := c.emit(code.OpIterNext, 9999)
+	c.emit(code.OpStep)
+
+	table := NewEnclosedSymbolTable(c.symbolTable)
+	variable := table.DefineParameter(node.Variable.Value)
+	declare(table, node.Body)
+	blocks := c.scope().blocks
+	pushPos := c.enterBlock(table)
+	c.setSymbol(variable)
+
+	c.enterLoop(blocks, blocks+1)
+	if err := c.compileBody(node.Body); err != nil {
+		return err
+	}
+
+	continuePos := len(c.currentInstructions())
+	c.leaveBlock(pushPos)
+	c.emit(code.OpJump, startPos)
+
+	c.changeOperand(exitPos, len(c.currentInstructions()))
+	c.leaveLoop(len(c.currentInstructions()), continuePos)
+	c.emit(code.OpNull)
+	return nil
+}
+
+// compileBody compiles the body of a loop, the value of which is not used
+func (c *Compiler) compileBody(body *ast.BlockStatement) error {
+	if err := c.compileStatements(body.Statements, false); err != nil {
+		return err
+	}
+	c.emit(code.OpPop)
+	return nil
+}
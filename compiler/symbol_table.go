@@ -0,0 +1,104 @@
+package compiler
+
+import "github.com/shanehowearth/interpreter/ast"
+
+// SymbolScope -
+type SymbolScope string
+
+// nolint: revive
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+)
+
+// Symbol - a variable and the slot it is held in
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	Depth int // for a local, the number of scopes out from where it is used
+}
+
+// SymbolTable - the variables of a scope. The outermost table holds the
+// globals, each enclosed one the variables of a call or a block that has a
+// scope of its own in the vm.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+}
+
+// NewSymbolTable -
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	return &SymbolTable{store: s}
+}
+
+// NewEnclosedSymbolTable -
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define - the slot of name in this scope, a name defined again keeps its
+// slot, as let binds it again in the same environment in the evaluator
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok {
+		return symbol
+	}
+	return s.DefineParameter(name)
+}
+
+// DefineParameter - a new slot for name, the parameters of a call are held in
+// the first slots in their order, so a repeated name takes another slot
+func (s *SymbolTable) DefineParameter(name string) Symbol {
+	symbol := s.defineHidden()
+	symbol.Name = name
+	s.store[name] = symbol
+	return symbol
+}
+
+// defineHidden is a slot that no name resolves to, for the compiler's own use
+func (s *SymbolTable) defineHidden() Symbol {
+	symbol := Symbol{Scope: LocalScope, Index: s.numDefinitions}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	}
+	s.numDefinitions++
+	return symbol
+}
+
+// Resolve - the nearest definition of name
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	depth := 0
+	for table := s; table != nil; table = table.Outer {
+		if symbol, ok := table.store[name]; ok {
+			symbol.Depth = depth
+			return symbol, true
+		}
+		depth++
+	}
+	return Symbol{}, false
+}
+
+// resolveIdentifier finds the symbol of the variable the resolver found
+// ident to refer to, which need not be the innermost one of that name when
+// that one is declared after ident. A name that is not a local variable is a
+// global, if there is one.
+func (s *SymbolTable) resolveIdentifier(ident *ast.Identifier) (Symbol, bool) {
+	table := s
+	if ident.Local {
+		for depth := 0; depth < ident.Depth; depth++ {
+			table = table.Outer
+		}
+		symbol, ok := table.store[ident.Value]
+		symbol.Depth = ident.Depth
+		return symbol, ok
+	}
+	for table.Outer != nil {
+		table = table.Outer
+	}
+	return table.Resolve(ident.Value)
+}
@@ -0,0 +1,129 @@
+package compiler
+
+import (
+	"github.com/shanehowearth/interpreter/ast"
+	"github.com/shanehowearth/interpreter/code"
+)
+
+// compileTry compiles a try expression. A handler is installed for the try
+// block, and for the catch block when there is a finally block to run after
+// an error in it. The finally block is compiled wherever the try expression
+// can be left: at its end, on an error, and at each return, break or
+// continue in it.
+func (c *Compiler) compileTry(node *ast.TryExpression) error {
+	setupPos := c.emit(code.OpSetupTry, 9999)
+	c.enterTry(node.Finally)
+	if err := c.compileStatements(node.Block.Statements, false); err != nil {
+		return err
+	}
+	c.leaveTry()
+	if err := c.compileFinally(node.Finally); err != nil {
+		return err
+	}
+	donePos := []int{c.emit(code.OpJump, 9999)}
+
+	// the error raised in the try block is on the stack
+	c.changeOperand(setupPos, len(c.currentInstructions()))
+	if node.Catch != nil {
+		if node.Finally != nil {
+			setupPos = c.emit(code.OpSetupTry, 9999)
+			c.enterTry(node.Finally)
+		}
+		if err := c.compileCatch(node); err != nil {
+			return err
+		}
+		if node.Finally == nil {
+			c.changeOperand(donePos[0], len(c.currentInstructions()))
+			return nil
+		}
+		c.leaveTry()
+		if err := c.compileFinally(node.Finally); err != nil {
+			return err
+		}
+		donePos = append(donePos, c.emit(code.OpJump, 9999))
+
+		// the error raised in the catch block is on the stack
+		c.changeOperand(setupPos, len(c.currentInstructions()))
+	}
+	if err := c.compileFinally(node.Finally); err != nil {
+		return err
+	}
+	c.emit(code.OpRethrow)
+
+	for _, pos := range donePos {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	return nil
+}
+
+// compileCatch binds the error on the stack in the block scope of the catch
+// block and compiles the block
+func (c *Compiler) compileCatch(node *ast.TryExpression) error {
+	c.emit(code.OpCatch)
+
+	table := NewEnclosedSymbolTable(c.symbolTable)
+	param := table.DefineParameter(node.CatchParam.Value)
+	declare(table, node.Catch)
+	pushPos := c.enterBlock(table)
+	c.setSymbol(param)
+
+	if err := c.compileStatements(node.Catch.Statements, false); err != nil {
+		return err
+	}
+	c.leaveBlock(pushPos)
+	return nil
+}
+
+// compileFinally compiles a finally block, if there is one, discarding its
+// value
+func (c *Compiler) compileFinally(finally *ast.BlockStatement) error {
+	if finally == nil {
+		return nil
+	}
+	if err := c.compileStatements(finally.Statements, false); err != nil {
+		return err
+	}
+	c.emit(code.OpPop)
+	return nil
+}
+
+// enterTry starts a block that has a handler installed
+func (c *Compiler) enterTry(finally *ast.BlockStatement) {
+	scope := c.scope()
+	scope.tries = append(scope.tries, &tryBlock{
+		finally:     finally,
+		blocks:      scope.blocks,
+		symbolTable: c.symbolTable,
+	})
+}
+
+// leaveTry removes the handler of the innermost try block
+func (c *Compiler) leaveTry() {
+	scope := c.scope()
+	scope.tries = scope.tries[:len(scope.tries)-1]
+	c.emit(code.OpPopTry)
+}
+
+// leave prepares a jump out of the try blocks entered since there were tries
+// of them, to where there are blocks block scopes: the handlers are removed
+// and the finally blocks run, innermost first, each in its own scope
+func (c *Compiler) leave(tries, blocks int) error {
+	scope := c.scope()
+	savedTries := append([]*tryBlock(nil), scope.tries...)
+	savedBlocks, savedTable := scope.blocks, c.symbolTable
+	defer func() {
+		scope.tries, scope.blocks, c.symbolTable = savedTries, savedBlocks, savedTable
+	}()
+
+	for len(scope.tries) > tries {
+		t := scope.tries[len(scope.tries)-1]
+		c.popBlocks(t.blocks)
+		c.leaveTry()
+		c.symbolTable = t.symbolTable
+		if err := c.compileFinally(t.finally); err != nil {
+			return err
+		}
+	}
+	c.popBlocks(blocks)
+	return nil
+}
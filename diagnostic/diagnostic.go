@@ -0,0 +1,100 @@
+// Package diagnostic -
+package diagnostic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shanehowearth/interpreter/token"
+)
+
+// Severity -
+type Severity int
+
+// Severities, from most to least severe
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+// String -
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// MarshalText - severities are written as their name in machine readable output
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Code - a stable identifier for a kind of diagnostic, so that tools can match
+// on it without parsing the message
+type Code string
+
+// Lexer codes
+const (
+	IllegalCharacter    Code = "L0001"
+	UnterminatedComment Code = "L0002"
+	UnterminatedString  Code = "L0003"
+	InvalidEscape       Code = "L0004"
+)
+
+// Parser codes
+const (
+	UnexpectedToken Code = "P0001"
+	MissingPrefix   Code = "P0002"
+	InvalidInteger  Code = "P0003"
+	InvalidFloat    Code = "P0004"
+	InvalidTarget   Code = "P0005"
+	OutsideLoop     Code = "P0006"
+)
+
+// Resolver codes
+const (
+	UseBeforeDeclaration Code = "R0001"
+)
+
+// Diagnostic - a problem found in a source file
+type Diagnostic struct {
+	Severity Severity          `json:"severity"`
+	Code     Code              `json:"code"`
+	Span     token.Span        `json:"span"`
+	Message  string            `json:"message"`
+	Expected []token.TokenType `json:"expected,omitempty"` // the tokens that would have been accepted
+	Actual   token.TokenType   `json:"actual,omitempty"`   // the token that was found instead
+	Hint     string            `json:"hint,omitempty"`     // a suggestion on how to fix the problem
+}
+
+// Pos - the position the diagnostic starts at
+func (d Diagnostic) Pos() token.Position {
+	return d.Span.Start
+}
+
+// String - the one line form, e.g. 2:5: error[P0001]: expected ...
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s[%s]: %s", d.Span.Start, d.Severity, d.Code, d.Message)
+}
+
+// Error - a Diagnostic can be used as a Go error
+func (d Diagnostic) Error() string {
+	return d.String()
+}
+
+// ExpectedString - the expected token set in a human readable form, e.g. ")" or "," or ")"
+func (d Diagnostic) ExpectedString() string {
+	quoted := make([]string, len(d.Expected))
+	for idx, t := range d.Expected {
+		quoted[idx] = fmt.Sprintf("%q", t)
+	}
+	return strings.Join(quoted, " or ")
+}
@@ -0,0 +1,74 @@
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Render writes d followed by an excerpt of source with the offending span
+// underlined, e.g.
+//
+//	2:5: error[P0001]: expected next token to be "IDENT", got "=" instead
+//	  |
+//	2 | let = 10;
+//	  |     ^
+//	  = hint: ...
+//
+// source is the full text the diagnostic's positions refer to. When the
+// position is unknown, or not in source, only the first line is written.
+func Render(w io.Writer, source string, d Diagnostic) error {
+	if _, err := fmt.Fprintln(w, d.String()); err != nil {
+		return err
+	}
+
+	start := d.Span.Start
+	lines := strings.Split(source, "\n")
+	if !start.IsValid() || start.Line > len(lines) {
+		return renderHint(w, "", d)
+	}
+	line := strings.TrimRight(lines[start.Line-1], "\r")
+
+	gutter := strings.Repeat(" ", len(strconv.Itoa(start.Line)))
+	excerpt := fmt.Sprintf("%s |\n%d | %s\n%s | %s\n",
+		gutter, start.Line, line, gutter, underline(line, d))
+	if _, err := io.WriteString(w, excerpt); err != nil {
+		return err
+	}
+	return renderHint(w, gutter, d)
+}
+
+func renderHint(w io.Writer, gutter string, d Diagnostic) error {
+	if d.Hint == "" {
+		return nil
+	}
+	_, err := fmt.Fprintf(w, "%s = hint: %s\n", gutter, d.Hint)
+	return err
+}
+
+// underline builds the caret line for the span of d within line. Tabs before
+// the span are kept so that the carets line up with the excerpt.
+func underline(line string, d Diagnostic) string {
+	runes := []rune(line)
+	startCol := d.Span.Start.Column - 1
+	if startCol > len(runes) {
+		startCol = len(runes)
+	}
+
+	width := 1
+	if d.Span.End.Line == d.Span.Start.Line && d.Span.End.Column > d.Span.Start.Column {
+		width = d.Span.End.Column - d.Span.Start.Column
+	}
+
+	var out strings.Builder
+	for _, r := range runes[:startCol] {
+		if r == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+	}
+	out.WriteString(strings.Repeat("^", width))
+	return out.String()
+}
@@ -0,0 +1,71 @@
+package diagnostic
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/shanehowearth/interpreter/token"
+)
+
+func TestRender(t *testing.T) {
+	tests := map[string]struct {
+		source   string
+		d        Diagnostic
+		expected string
+	}{
+		"single caret": {
+			source: "let x = 5;\nlet = 10;",
+			d: Diagnostic{
+				Severity: Error,
+				Code:     UnexpectedToken,
+				Span: token.Span{
+					Start: token.Position{Offset: 15, Line: 2, Column: 5},
+					End:   token.Position{Offset: 16, Line: 2, Column: 6},
+				},
+				Message: `expected next token to be "IDENT", got "=" instead`,
+			},
+			expected: `2:5: error[P0001]: expected next token to be "IDENT", got "=" instead
+  |
+2 | let = 10;
+  |     ^
+`,
+		},
+		"wide span and hint": {
+			source: "\tfoo(12345",
+			d: Diagnostic{
+				Severity: Warning,
+				Code:     InvalidInteger,
+				Span: token.Span{
+					Start: token.Position{Offset: 5, Line: 1, Column: 6},
+					End:   token.Position{Offset: 10, Line: 1, Column: 11},
+				},
+				Message: "bad number",
+				Hint:    "try a smaller one",
+			},
+			expected: "1:6: warning[P0003]: bad number\n" +
+				"  |\n" +
+				"1 | \tfoo(12345\n" +
+				"  | \t    ^^^^^\n" +
+				"  = hint: try a smaller one\n",
+		},
+		"no position": {
+			source: "",
+			d: Diagnostic{
+				Severity: Note,
+				Code:     MissingPrefix,
+				Message:  "somewhere",
+			},
+			expected: "-: note[P0002]: somewhere\n",
+		},
+	}
+
+	for name, tt := range tests {
+		var out bytes.Buffer
+		if err := Render(&out, tt.source, tt.d); err != nil {
+			t.Fatalf("%s: Render returned error %v", name, err)
+		}
+		if out.String() != tt.expected {
+			t.Errorf("%s: wrong output.\nexpected=\n%s\ngot=\n%s", name, tt.expected, out.String())
+		}
+	}
+}
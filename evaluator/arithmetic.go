@@ -0,0 +1,66 @@
+package evaluator
+
+import "math"
+
+// Checked int64 arithmetic, each reports whether the result overflowed
+
+func addInt64(a, b int64) (int64, bool) {
+	c := a + b
+	// overflow when both operands have the same sign and the result does not
+	return c, (c^a)&(c^b) < 0
+}
+
+func subInt64(a, b int64) (int64, bool) {
+	c := a - b
+	// overflow when the operands have different signs and the result does
+	// not have the sign of a
+	return c, (a^b)&(a^c) < 0
+}
+
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, false
+	}
+	c := a * b
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return c, true
+	}
+	return c, c/b != a
+}
+
+func divInt64(a, b int64) (int64, bool) {
+	// the one division that overflows, the result would be MaxInt64 + 1
+	return a / b, a == math.MinInt64 && b == -1
+}
+
+func negInt64(a int64) (int64, bool) {
+	return -a, a == math.MinInt64
+}
+
+// powInt64 raises base to a non-negative exp by repeated squaring
+func powInt64(base, exp int64) (int64, bool) {
+	result := int64(1)
+	overflow := false
+	for exp > 0 {
+		var o bool
+		if exp&1 == 1 {
+			result, o = mulInt64(result, base)
+			overflow = overflow || o
+		}
+		exp >>= 1
+		if exp > 0 {
+			base, o = mulInt64(base, base)
+			overflow = overflow || o
+		}
+	}
+	return result, overflow
+}
+
+func shlInt64(a, n int64) (int64, bool) {
+	if n >= 64 {
+		return 0, a != 0
+	}
+	c := a << uint64(n)
+	// overflow when shifting back does not restore the original value
+	return c, c>>uint64(n) != a
+}
@@ -0,0 +1,121 @@
+package evaluator
+
+import (
+	"math/big"
+
+	"github.com/shanehowearth/interpreter/object"
+)
+
+// maxBigShift caps shift counts and exponents on big integers, larger results
+// would not fit in memory anyway
+const maxBigShift = 1 << 24
+
+func isInteger(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInt:
+		return true
+	default:
+		return false
+	}
+}
+
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInt:
+		return obj.Value
+	default:
+		return new(big.Int)
+	}
+}
+
+// newInteger returns value as an Integer when it fits in an int64, so that a
+// BigInt is only ever used for values an Integer cannot hold
+func newInteger(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+	return &object.BigInt{Value: value}
+}
+
+// evalBigIntInfixExpression evaluates an operation between two integers where
+// at least one is a BigInt, or the int64 result overflowed. The operands are
+// never modified, every result is a new big.Int.
+func evalBigIntInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := toBigInt(left)
+	rightVal := toBigInt(right)
+	result := new(big.Int)
+
+	switch operator {
+	case "+":
+		result.Add(leftVal, rightVal)
+	case "-":
+		result.Sub(leftVal, rightVal)
+	case "*":
+		result.Mul(leftVal, rightVal)
+	case "/":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero: %s / 0", leftVal)
+		}
+		// Quo truncates towards zero, the same as int64 division
+		result.Quo(leftVal, rightVal)
+	case "%":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero: %s %% 0", leftVal)
+		}
+		result.Rem(leftVal, rightVal)
+	case "**":
+		if rightVal.Sign() < 0 {
+			return newError("negative exponent: %s ** %s", leftVal, rightVal)
+		}
+		if !rightVal.IsInt64() || rightVal.Int64() > maxBigShift {
+			return newError("exponent too large: %s ** %s", leftVal, rightVal)
+		}
+		result.Exp(leftVal, rightVal, nil)
+	case "&":
+		result.And(leftVal, rightVal)
+	case "|":
+		result.Or(leftVal, rightVal)
+	case "^":
+		result.Xor(leftVal, rightVal)
+	case "<<", ">>":
+		if rightVal.Sign() < 0 {
+			return newError("negative shift count: %s %s %s", leftVal, operator, rightVal)
+		}
+		if !rightVal.IsInt64() || rightVal.Int64() > maxBigShift {
+			if operator == ">>" {
+				// everything has been shifted out, only the sign is left
+				if leftVal.Sign() < 0 {
+					return &object.Integer{Value: -1}
+				}
+				return &object.Integer{Value: 0}
+			}
+			return newError("shift count too large: %s << %s", leftVal, rightVal)
+		}
+		if operator == "<<" {
+			result.Lsh(leftVal, uint(rightVal.Int64()))
+		} else {
+			result.Rsh(leftVal, uint(rightVal.Int64()))
+		}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+	return newInteger(result)
+}
@@ -0,0 +1,41 @@
+package evaluator
+
+import (
+	"errors"
+
+	"github.com/shanehowearth/interpreter/object"
+)
+
+// ErrBudgetExceeded - the Cause of the error evaluation stops with once the
+// step budget has been used up
+var ErrBudgetExceeded = errors.New("step budget exceeded")
+
+// WithStepBudget - the number of steps an evaluation may take, where a step is
+// a function call or a loop iteration. A budget of 0 or less is unlimited.
+func WithStepBudget(steps int64) Option {
+	return func(e *Evaluator) {
+		e.maxSteps = steps
+	}
+}
+
+// step counts a call or a loop iteration, returning an error when evaluation
+// has to stop
+func (e *Evaluator) step() *object.Error {
+	e.steps++
+	if e.maxSteps > 0 && e.steps > e.maxSteps {
+		return stopped(ErrBudgetExceeded)
+	}
+	select {
+	case <-e.ctx.Done():
+		return stopped(e.ctx.Err())
+	default:
+		return nil
+	}
+}
+
+// stopped is the error evaluation stops with for cause
+func stopped(cause error) *object.Error {
+	err := newError("evaluation stopped: %s", cause)
+	err.Cause = cause
+	return err
+}
@@ -0,0 +1,91 @@
+package evaluator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shanehowearth/interpreter/ast"
+	"github.com/shanehowearth/interpreter/compiler"
+	"github.com/shanehowearth/interpreter/diagnostic"
+	"github.com/shanehowearth/interpreter/evaluator"
+	"github.com/shanehowearth/interpreter/object"
+	"github.com/shanehowearth/interpreter/optimizer"
+	"github.com/shanehowearth/interpreter/vm"
+)
+
+// TestVM runs the evaluator tests against programs compiled and run by the vm.
+func TestVM(t *testing.T) {
+	runConformance(t, func(ctx context.Context, program *ast.Program, opts ...evaluator.Option) object.Object {
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			d, ok := err.(diagnostic.Diagnostic)
+			if !ok {
+				t.Fatalf("compiler error: %s", err)
+			}
+			return &object.Error{Message: d.Message, Pos: d.Pos()}
+		}
+		return vm.New(comp.Bytecode(), opts...).RunContext(ctx)
+	})
+}
+
+// TestOptimizer runs the evaluator tests against optimized programs
+func TestOptimizer(t *testing.T) {
+	eval := evaluator.EvalProgram
+	runConformance(t, func(ctx context.Context, program *ast.Program, opts ...evaluator.Option) object.Object {
+		return eval(ctx, optimizer.Optimize(program), opts...)
+	})
+}
+
+// runConformance runs the evaluator tests with evalProgram in place of
+// evaluator.EvalProgram. TestFunctionObject is left out, it checks the
+// representation of functions in the evaluator.
+func runConformance(t *testing.T, evalProgram func(context.Context, *ast.Program, ...evaluator.Option) object.Object) {
+	saved := evaluator.EvalProgram
+	defer func() { evaluator.EvalProgram = saved }()
+	evaluator.EvalProgram = evalProgram
+
+	tests := map[string]func(*testing.T){
+		"EvalIntegerExpression": evaluator.TestEvalIntegerExpression,
+		"EvalBooleanExpression": evaluator.TestEvalBooleanExpression,
+		"BangOperator":          evaluator.TestBangOperator,
+		"IfElseExpressions":     evaluator.TestIfElseExpressions,
+		"ReturnStatements":      evaluator.TestReturnStatements,
+		"ErrorHandling":         evaluator.TestErrorHandling,
+		"LetStatements":         evaluator.TestLetStatements,
+		"FunctionApplication":   evaluator.TestFunctionApplication,
+		"FunctionArity":         evaluator.TestFunctionArity,
+		"Closures":              evaluator.TestClosures,
+		"StringLiteral":         evaluator.TestStringLiteral,
+		"StringConcatenation":   evaluator.TestStringConcatenation,
+		"BuiltinFunctions":      evaluator.TestBuiltinFunctions,
+		"ArrayLiterals":         evaluator.TestArrayLiterals,
+		"ArrayIndexExpressions": evaluator.TestArrayIndexExpressions,
+		"HashLiterals":          evaluator.TestHashLiterals,
+		"HashOrder":             evaluator.TestHashOrder,
+		"HashIndexExpressions":  evaluator.TestHashIndexExpressions,
+		"ErrorPositions":        evaluator.TestErrorPositions,
+		"EvalFloatExpression":   evaluator.TestEvalFloatExpression,
+		"MixedNumberComparison": evaluator.TestMixedNumberComparison,
+		"InterpolatedStrings":   evaluator.TestInterpolatedStrings,
+		"LogicalAndComparison":  evaluator.TestLogicalAndComparisonOperators,
+		"Assignment":            evaluator.TestAssignment,
+		"IndexAssignment":       evaluator.TestIndexAssignment,
+		"Loops":                 evaluator.TestLoops,
+		"TailCalls":             evaluator.TestTailCalls,
+		"MaxDepth":              evaluator.TestMaxDepth,
+		"EvalContext":           evaluator.TestEvalContext,
+		"MemoryLimit":           evaluator.TestMemoryLimit,
+		"ErrorStack":            evaluator.TestErrorStack,
+		"TryCatch":              evaluator.TestTryCatch,
+		"IntegerOperators":      evaluator.TestIntegerOperators,
+		"FloatOperators":        evaluator.TestFloatOperators,
+		"OperatorErrors":        evaluator.TestOperatorErrors,
+		"DivisionByZero":        evaluator.TestDivisionByZero,
+		"CheckedArithmetic":     evaluator.TestCheckedArithmetic,
+		"BigIntegers":           evaluator.TestBigIntegers,
+		"Scopes":                evaluator.TestScopes,
+	}
+	for name, test := range tests {
+		t.Run(name, test)
+	}
+}
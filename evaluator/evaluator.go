@@ -2,112 +2,233 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"math/big"
+	"strings"
 
 	"github.com/shanehowearth/interpreter/ast"
+	"github.com/shanehowearth/interpreter/diagnostic"
 	"github.com/shanehowearth/interpreter/object"
+	"github.com/shanehowearth/interpreter/resolver"
 )
 
 // nolint: revive
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
-// Eval -
+// DefaultMaxDepth - the number of nested calls an Evaluator allows, unless
+// WithMaxDepth says otherwise
+const DefaultMaxDepth = 10000
+
+// Evaluator - evaluates AST nodes, holding the settings and the call stack of
+// an evaluation. An Evaluator is not safe for concurrent use.
+type Evaluator struct {
+	checked   bool // report integer overflow as an error
+	maxDepth  int
+	maxSteps  int64
+	maxMemory int64
+	frames    []object.Frame // the calls being evaluated, innermost last
+
+	ctx       context.Context
+	steps     int64 // the steps taken in the current evaluation
+	allocated int64 // the bytes allocated in the current evaluation
+}
+
+// Option - configures an Evaluator
+type Option func(*Evaluator)
+
+// WithCheckedArithmetic - report integer overflow as an error, rather than
+// promoting the result to a BigInt
+func WithCheckedArithmetic() Option {
+	return func(e *Evaluator) {
+		e.checked = true
+	}
+}
+
+// WithMaxDepth - the number of nested calls allowed before evaluation stops
+// with a "maximum recursion depth exceeded" error, instead of overflowing the
+// Go stack. Calls in tail position do not count, as they do not use the Go
+// stack, a function calling itself forever that way is stopped by
+// WithStepBudget or the context instead. A depth of 0 or less removes the
+// limit.
+func WithMaxDepth(depth int) Option {
+	return func(e *Evaluator) {
+		e.maxDepth = depth
+	}
+}
+
+// stack returns a copy of the current call stack, outermost call first
+func (e *Evaluator) stack() []object.Frame {
+	stack := make([]object.Frame, len(e.frames))
+	copy(stack, e.frames)
+	return stack
+}
+
+// New -
+func New(opts ...Option) *Evaluator {
+	e := &Evaluator{maxDepth: DefaultMaxDepth}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Eval - evaluates node with the default settings
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New().Eval(node, env)
+}
+
+// Eval - evaluates node in env, without a deadline
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	return e.EvalContext(context.Background(), node, env)
+}
+
+// EvalContext - evaluates node in env, stopping with an error once ctx is done
+// or the step budget given by WithStepBudget has been used up. The error's
+// Cause is then ctx.Err() or ErrBudgetExceeded.
+//
+// A program is resolved first, and not run if the resolver reports an error.
+// The globals env already has, from earlier programs, can be used before the
+// program binds them again.
+func (e *Evaluator) EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	if program, ok := node.(*ast.Program); ok {
+		bound := func(name string) bool {
+			_, ok := env.Get(name)
+			return ok
+		}
+		for _, d := range resolver.Resolve(program, resolver.WithGlobals(bound)) {
+			if d.Severity == diagnostic.Error {
+				return &object.Error{Message: d.Message, Pos: d.Pos()}
+			}
+		}
+	}
+	e.Start(ctx)
+	return e.eval(node, env)
+}
 
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	// Statements
 	case *ast.Program:
-		return evalProgram(node, env)
+		return e.evalProgram(node, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.eval(node.Expression, env)
 	// Expressions
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.BigIntegerLiteral:
+		return &object.BigInt{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := e.eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return withPos(e.evalPrefixExpression(node.Operator, right), node)
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
+		if node.Operator == "&&" || node.Operator == "||" {
+			return e.evalLogicalExpression(node, env)
+		}
+		left := e.eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
+		right := e.eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return withPos(e.evalInfixExpression(node.Operator, left, right), node)
+	case *ast.AssignExpression:
+		return e.evalAssignExpression(node, env)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
+	case *ast.TryExpression:
+		return e.evalTryExpression(node, env)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		// a returned call is in tail position, wherever the return is
+		val := e.evalTail(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
+	case *ast.WhileStatement:
+		return e.evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return e.evalForStatement(node, env)
+	case *ast.ForInStatement:
+		return e.evalForInStatement(node, env)
+	case *ast.ThrowStatement:
+		return e.evalThrowStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
+		// a break, continue or return in an if expression leaves the let
+		// statement like an error does
+		val := e.eval(node.Value, env)
+		if isInterrupt(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		bind(node.Name, env, val)
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return withPos(evalIdentifier(node, env), node)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Name: node.Name, Parameters: params, Env: env, Body: body, Slots: node.Slots}
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
+		function, args, err := e.evalCall(node, env)
+		if err != nil {
+			return err
 		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
-		}
-		return applyFunction(function, args)
+		return withPos(e.applyFunction(function, args, node), node)
 	case *ast.StringLiteral:
+		// not counted towards the memory limit, the bytes are the program's
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return e.evalInterpolatedString(node, env)
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
+		elements := e.evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return e.allocate(&object.Array{Elements: elements})
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return withPos(e.evalHashLiteral(node, env), node)
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
+		left := e.eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		index := Eval(node.Index, env)
+		index := e.eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
-		return evalIndexExpression(left, index)
+		return withPos(evalIndexExpression(left, index), node)
 	}
 	return nil
 }
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = e.eval(statement, env)
 		switch result := result.(type) {
 		case *object.ReturnValue:
-			return result.Value
+			return e.runTailCalls(result.Value)
 		case *object.Error:
 			return result
 		}
@@ -121,12 +242,14 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return FALSE
 }
 
-func evalPrefixExpression(operator string, right object.Object) object.Object {
+func (e *Evaluator) evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
-		return evalMinusPrefixOperatorExpression(right)
+		return e.allocate(e.evalMinusPrefixOperatorExpression(right))
+	case "~":
+		return e.allocate(evalTildePrefixOperatorExpression(right))
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
@@ -145,21 +268,50 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+func (e *Evaluator) evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		value, overflow := negInt64(right.Value)
+		if overflow {
+			if e.checked {
+				return newError("integer overflow: -(%d)", right.Value)
+			}
+			return newInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
+		return &object.Integer{Value: value}
+	case *object.BigInt:
+		return newInteger(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
-func evalInfixExpression(
+func evalTildePrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: ^right.Value}
+	case *object.BigInt:
+		return newInteger(new(big.Int).Not(right.Value))
+	default:
+		return newError("unknown operator: ~%s", right.Type())
+	}
+}
+
+func (e *Evaluator) evalInfixExpression(
 	operator string,
 	left, right object.Object,
 ) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(operator, left, right)
+		return e.evalIntegerInfixExpression(operator, left, right)
+	case isInteger(left) && isInteger(right):
+		return e.allocate(evalBigIntInfixExpression(operator, left, right))
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
+	// other than numbers values are compared by identity, not by Equals,
+	// which is for hash keys
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
@@ -168,32 +320,115 @@ func evalInfixExpression(
 		return newError("type mismatch: %s %s %s",
 			left.Type(), operator, right.Type())
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
-		return evalStringInfixExpression(operator, left, right)
+		return e.allocate(evalStringInfixExpression(operator, left, right))
 	default:
 		return newError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
 }
 
-func evalIntegerInfixExpression(
+func (e *Evaluator) evalIntegerInfixExpression(
 	operator string,
 	left, right object.Object,
 ) object.Object {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
+
+	var result int64
+	var overflow bool
+	switch operator {
+	case "+":
+		result, overflow = addInt64(leftVal, rightVal)
+	case "-":
+		result, overflow = subInt64(leftVal, rightVal)
+	case "*":
+		result, overflow = mulInt64(leftVal, rightVal)
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero: %d / 0", leftVal)
+		}
+		result, overflow = divInt64(leftVal, rightVal)
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero: %d %% 0", leftVal)
+		}
+		result = leftVal % rightVal
+	case "**":
+		if rightVal < 0 {
+			return newError("negative exponent: %d ** %d", leftVal, rightVal)
+		}
+		result, overflow = powInt64(leftVal, rightVal)
+	case "&":
+		result = leftVal & rightVal
+	case "|":
+		result = leftVal | rightVal
+	case "^":
+		result = leftVal ^ rightVal
+	case "<<":
+		if rightVal < 0 {
+			return newError("negative shift count: %d << %d", leftVal, rightVal)
+		}
+		result, overflow = shlInt64(leftVal, rightVal)
+	case ">>":
+		if rightVal < 0 {
+			return newError("negative shift count: %d >> %d", leftVal, rightVal)
+		}
+		result = leftVal >> uint64(rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+
+	if overflow {
+		if e.checked {
+			return newError("integer overflow: %d %s %d", leftVal, operator, rightVal)
+		}
+		return e.allocate(evalBigIntInfixExpression(operator, left, right))
+	}
+	return &object.Integer{Value: result}
+}
+
+// evalFloatInfixExpression evaluates an operation between two numbers where at
+// least one is a Float, the other is converted to a Float first
+func evalFloatInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		return &object.Float{Value: leftVal + rightVal}
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		return &object.Float{Value: leftVal - rightVal}
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		return &object.Float{Value: leftVal * rightVal}
 	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -204,17 +439,60 @@ func evalIntegerInfixExpression(
 	}
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
+func isNumber(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInt, *object.Float:
+		return true
+	default:
+		return false
+	}
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInt:
+		value, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return value
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
+	}
+}
+
+// evalLogicalExpression evaluates && and ||, the right operand is only
+// evaluated when the left one does not already decide the result
+func (e *Evaluator) evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := e.eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if node.Operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+	right := e.eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 
 	}
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return e.eval(ie.Consequence, env)
 
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return e.eval(ie.Alternative, env)
 
 	} else {
 		return NULL
@@ -237,15 +515,12 @@ func isTruthy(obj object.Object) bool {
 
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
-		if result != nil {
-			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
-				return result
-			}
+		result = e.eval(statement, env)
+		if isInterrupt(result) {
+			return result
 		}
 	}
 	return result
@@ -256,6 +531,15 @@ func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
+// withPos records the position of node on obj if it is an error that has not
+// been given a position yet, i.e. the error was raised by node itself
+func withPos(obj object.Object, node ast.Node) object.Object {
+	if err, ok := obj.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+	return obj
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.ERROR_OBJ
@@ -263,11 +547,21 @@ func isError(obj object.Object) bool {
 	return false
 }
 
+// isInterrupt reports whether obj stops the statements it is the result of
+// from running on, an error, a return, a break or a continue
+func isInterrupt(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Error, *object.ReturnValue, *object.Break, *object.Continue:
+		return true
+	}
+	return false
+}
+
 func evalIdentifier(
 	node *ast.Identifier,
 	env *object.Environment,
 ) object.Object {
-	if val, ok := env.Get(node.Value); ok {
+	if val, ok := lookup(node, env); ok {
 		return val
 	}
 	if builtin, ok := builtins[node.Value]; ok {
@@ -276,13 +570,154 @@ func evalIdentifier(
 	return newError("identifier not found: " + node.Value)
 }
 
-func evalExpressions(
+// evalAssignExpression updates the nearest binding of the target, or the
+// element of an array or hash in place, a compound assignment such as x += 1
+// applies the operator to the current value first
+func (e *Evaluator) evalAssignExpression(
+	node *ast.AssignExpression,
+	env *object.Environment,
+) object.Object {
+	if target, ok := node.Target.(*ast.IndexExpression); ok {
+		return e.evalIndexAssignment(node, target, env)
+	}
+
+	ident := node.Target.(*ast.Identifier)
+	current, ok := lookup(ident, env)
+	if !ok {
+		return withPos(newError("assignment to undeclared variable: %s", ident.Value), ident)
+	}
+
+	val := e.eval(node.Value, env)
+	if isInterrupt(val) {
+		return val
+	}
+	val = e.evalCompoundOperator(node, current, val)
+	if isError(val) {
+		return val
+	}
+
+	assign(ident, env, val)
+	return val
+}
+
+// lookup finds the value of the variable ident names, in its slot if the
+// resolver gave it one and by name otherwise. A slot that has not been bound,
+// such as that of a let in a branch that was not taken, is not found, the
+// variable does not fall back to one of the same name further out, as in the
+// vm.
+func lookup(ident *ast.Identifier, env *object.Environment) (object.Object, bool) {
+	if ident.Local {
+		val := env.Slot(ident.Depth, ident.Slot)
+		return val, val != nil
+	}
+	return env.Get(ident.Value)
+}
+
+// bind declares the variable ident names in env
+func bind(ident *ast.Identifier, env *object.Environment, val object.Object) {
+	if ident.Local {
+		env.SetSlot(0, ident.Slot, val)
+		return
+	}
+	env.Set(ident.Value, val)
+}
+
+// assign updates the binding lookup found for ident
+func assign(ident *ast.Identifier, env *object.Environment, val object.Object) {
+	if ident.Local {
+		env.SetSlot(ident.Depth, ident.Slot, val)
+		return
+	}
+	env.Assign(ident.Value, val)
+}
+
+// evalIndexAssignment sets an element of an array or a hash. A compound
+// assignment reads the current element before the value is evaluated, as it
+// does for a variable.
+func (e *Evaluator) evalIndexAssignment(
+	node *ast.AssignExpression,
+	target *ast.IndexExpression,
+	env *object.Environment,
+) object.Object {
+	left := e.eval(target.Left, env)
+	if isError(left) {
+		return left
+	}
+	index := e.eval(target.Index, env)
+	if isError(index) {
+		return index
+	}
+	var current object.Object
+	if node.Operator != "=" {
+		current = withPos(evalIndexExpression(left, index), target)
+		if isError(current) {
+			return current
+		}
+	}
+	val := e.eval(node.Value, env)
+	if isInterrupt(val) {
+		return val
+	}
+	if node.Operator != "=" {
+		val = e.evalCompoundOperator(node, current, val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	return withPos(e.setIndex(left, index, val), target)
+}
+
+// setIndex sets the element of left at index to val
+func (e *Evaluator) setIndex(left, index, val object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+		length := int64(len(left.Elements))
+		if idx.Value < 0 || idx.Value >= length {
+			return newError("index out of range: %d, length %d", idx.Value, length)
+		}
+		left.Elements[idx.Value] = val
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		if _, ok := left.Get(key); !ok {
+			if err := e.charge(pairSize); err != nil {
+				return err
+			}
+		}
+		left.Set(key, val)
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+	return val
+}
+
+// evalCompoundOperator applies the operator of a compound assignment, such as
+// the + of +=, to the current value of the target and the assigned value
+func (e *Evaluator) evalCompoundOperator(
+	node *ast.AssignExpression,
+	current, val object.Object,
+) object.Object {
+	if node.Operator == "=" {
+		return val
+	}
+	operator := strings.TrimSuffix(node.Operator, "=")
+	return withPos(e.evalInfixExpression(operator, current, val), node)
+}
+
+func (e *Evaluator) evalExpressions(
 	exps []ast.Expression,
 	env *object.Environment,
 ) []object.Object {
 	var result []object.Object
-	for _, e := range exps {
-		evaluated := Eval(e, env)
+	for _, exp := range exps {
+		evaluated := e.eval(exp, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -291,14 +726,66 @@ func evalExpressions(
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// evalCall evaluates the function and the arguments of a call, err is set
+// when either fails
+func (e *Evaluator) evalCall(
+	node *ast.CallExpression,
+	env *object.Environment,
+) (function object.Object, args []object.Object, err object.Object) {
+	function = e.eval(node.Function, env)
+	if isError(function) {
+		return nil, nil, function
+	}
+	args = e.evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return nil, nil, args[0]
+	}
+	return function, args, nil
+}
+
+func (e *Evaluator) applyFunction(
+	fn object.Object,
+	args []object.Object,
+	node *ast.CallExpression,
+) object.Object {
+	return e.runTailCalls(e.callFunction(fn, args, node))
+}
+
+// callFunction calls fn, the result is a tailCall when the body ends in one.
+// The call is on the call stack until its body has been evaluated, a tail
+// call is made after it has been taken off, so it does not add to the depth.
+func (e *Evaluator) callFunction(
+	fn object.Object,
+	args []object.Object,
+	node *ast.CallExpression,
+) object.Object {
+	if err := e.step(); err != nil {
+		return err
+	}
+
 	switch fn := fn.(type) {
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d", len(fn.Parameters), len(args))
+		}
+		frame := object.Frame{Function: fn.Name, Pos: node.Pos()}
+		if e.maxDepth > 0 && len(e.frames) >= e.maxDepth {
+			err := newError("maximum recursion depth exceeded")
+			err.Stack = append(e.stack(), frame)
+			return err
+		}
+
+		e.frames = append(e.frames, frame)
 		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		evaluated := e.evalTailBlock(fn.Body, extendedEnv)
+		if err, ok := evaluated.(*object.Error); ok && err.Stack == nil {
+			// the error was raised in this call, record how it was reached
+			err.Stack = e.stack()
+		}
+		e.frames = e.frames[:len(e.frames)-1]
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		return fn.Fn(args...)
+		return e.allocateBuiltinResult(fn.Fn(args...), args)
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
@@ -308,9 +795,9 @@ func extendFunctionEnv(
 	args []object.Object,
 
 ) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+	env := object.NewFrame(fn.Slots, fn.Env)
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		bind(param, env, args[paramIdx])
 
 	}
 	return env
@@ -340,6 +827,34 @@ func evalStringInfixExpression(
 	return &object.String{Value: leftVal + rightVal}
 }
 
+// evalInterpolatedString joins the parts of the string, converting the values
+// of embedded expressions with Inspect
+func (e *Evaluator) evalInterpolatedString(
+	node *ast.InterpolatedString,
+	env *object.Environment,
+) object.Object {
+	values := make([]object.Object, len(node.Parts))
+	for idx, part := range node.Parts {
+		value := e.eval(part, env)
+		if isError(value) {
+			return value
+		}
+		values[idx] = value
+	}
+	return e.interpolate(values)
+}
+
+func (e *Evaluator) interpolate(values []object.Object) object.Object {
+	var out strings.Builder
+	for _, value := range values {
+		if value == nil {
+			value = NULL
+		}
+		out.WriteString(value.Inspect())
+	}
+	return e.allocate(&object.String{Value: out.String()})
+}
+
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
@@ -363,14 +878,14 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	}
 }
 
-func evalHashLiteral(
+func (e *Evaluator) evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 
 ) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	hash := object.NewHash(len(node.Pairs))
+	for _, pair := range node.Pairs {
+		key := e.eval(pair.Key, env)
 		if isError(key) {
 			return key
 
@@ -380,16 +895,15 @@ func evalHashLiteral(
 			return newError("unusable as hash key: %s", key.Type())
 
 		}
-		value := Eval(valueNode, env)
+		value := e.eval(pair.Value, env)
 		if isError(value) {
 			return value
 
 		}
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 
 	}
-	return &object.Hash{Pairs: pairs}
+	return e.allocate(hash)
 
 }
 
@@ -400,7 +914,7 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 		return newError("unusable as hash key: %s", index.Type())
 
 	}
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key)
 	if !ok {
 		return NULL
 
@@ -1,8 +1,13 @@
 package evaluator
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/shanehowearth/interpreter/ast"
 	"github.com/shanehowearth/interpreter/lexer"
 	"github.com/shanehowearth/interpreter/object"
 	"github.com/shanehowearth/interpreter/parser"
@@ -36,12 +41,21 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+// EvalProgram - evaluates a program for the tests, the conformance tests of
+// the vm replace it to run the same tests against compiled programs
+var EvalProgram = func(ctx context.Context, program *ast.Program, opts ...Option) object.Object {
+	return New(opts...).EvalContext(ctx, program, object.NewEnvironment())
+}
+
 func testEval(input string) object.Object {
+	return testEvalWith(input)
+}
+
+func testEvalWith(input string, opts ...Option) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
-	env := object.NewEnvironment()
-	return Eval(program, env)
+	return EvalProgram(context.Background(), program, opts...)
 }
 
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
@@ -311,6 +325,36 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionArity(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`let f = fn(a, len) { len("ab") }; f(1)`, "wrong number of arguments: want=2, got=1"},
+		{"fn(a, b) { b }(1)", "wrong number of arguments: want=2, got=1"},
+		{"fn(a) { a }(1, 2)", "wrong number of arguments: want=1, got=2"},
+		{"let g = fn(a) { a }; let f = fn() { g() }; f()", "wrong number of arguments: want=1, got=0"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+
+	// the error can be caught like any other
+	evaluated := testEval(`try { fn(a) { a }() } catch (e) { e["message"] }`)
+	str, ok := evaluated.(*object.String)
+	if !ok || str.Value != "wrong number of arguments: want=1, got=0" {
+		t.Errorf("expected the error to be caught. got=%T(%+v)", evaluated, evaluated)
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 let newAdder = fn(x) {
@@ -474,23 +518,27 @@ false: 6
 	if !ok {
 		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
 	}
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{TRUE, 5},
+		{FALSE, 6},
 	}
 	if len(result.Pairs) != len(expected) {
 		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
 	}
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+	for _, tt := range expected {
+		pair, ok := result.Get(tt.key)
 		if !ok {
-			t.Errorf("no pair for given key in Pairs")
+			t.Errorf("no pair for key %s in Pairs", tt.key.Inspect())
+			continue
 		}
-		testIntegerObject(t, pair.Value, expectedValue)
+		testIntegerObject(t, pair.Value, tt.value)
 	}
 }
 
@@ -538,3 +586,968 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestHashOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, 3: 3, true: 4}`, "{b: 1, a: 2, 3: 3, true: 4}"},
+		// a key set again keeps its place
+		{`{"b": 1, "a": 2, "b": 3}`, "{b: 3, a: 2}"},
+		{`let h = {"z": 1}; h["y"] = 2; h["z"] = 3; h`, "{z: 3, y: 2}"},
+		{`let ks = []; for (k in {"c": 1, "a": 2, "b": 3}) { ks = push(ks, k) }; ks`, "[c, a, b]"},
+		// the pairs of a literal are evaluated in the order they are written
+		{`let log = []; let f = fn(x) { log = push(log, x); x }; {f(1): f(2), f(3): f(4)}; log`, "[1, 2, 3, 4]"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos string
+	}{
+		{"foobar", "1:1"},
+		{"let x = 5;\nlet y = x + true;", "2:11"},
+		{"let f = fn(a) {\n  a - \"b\"\n};\nf(1);", "2:5"},
+		{"len(1)", "1:4"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("wrong error position for %q. expected=%s, got=%s",
+				tt.input, tt.expectedPos, errObj.Pos)
+		}
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.5", 3.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3},
+		{"10 / 4.0", 2.5},
+		{"2 * 0.25", 0.5},
+		{"1e2 - 1", 99},
+		{"0.5 * 3 + 1", 2.5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func TestMixedNumberComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 < 1.5", true},
+		{"2.0 == 2", true},
+		{"2.5 > 3", false},
+		{"0.1 + 0.2 != 0.3", true},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Monkey"; "Hello ${name}!"`, "Hello Monkey!"},
+		{`let items = [1, 2, 3]; "you have ${len(items)} items"`, "you have 3 items"},
+		{`"${1 + 1} and ${2.5} and ${true} and ${[1, "a"]}"`, "2 and 2.5 and true and [1, a]"},
+		{`"outer ${"inner ${1}"}"`, "outer inner 1"},
+		{`let a = [1]; a[0] = a; "${a}"`, "[[...]]"},
+		{`let h = {}; h["h"] = h; "${h}"`, "{h: {...}}"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. expected=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+}
+
+func TestLogicalAndComparisonOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"2.5 >= 2", true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 0", true},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		// the right hand side would be an error if it were evaluated
+		{"false && foobar", false},
+		{"true || foobar", true},
+		{"let a = [1]; len(a) > 0 && a[0] > 0", true},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = 2", 2},
+		{"let x = 1; let y = 2; x = y = 3; x + y", 6},
+		{"let x = 10; x += 5; x", 15},
+		{"let x = 10; x -= 5; x", 5},
+		{"let x = 10; x *= 5; x", 50},
+		{"let x = 10; x /= 5; x", 2},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let x = 1.5; x *= 2; x", 3.0},
+		// assignment updates the nearest enclosing binding
+		{"let x = 1; let f = fn() { x = 2 }; f(); x", 2},
+		{"let x = 1; let f = fn(x) { x = 5 }; f(0); x", 1},
+		{"let x = 1; let f = fn() { let x = 3; x = 4; x }; f() + x", 5},
+		{`let counter = fn() { let n = 0; fn() { n += 1 } };
+		  let c = counter(); c(); c(); c()`, 3},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		}
+	}
+
+	errors := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"x = 5", "assignment to undeclared variable: x"},
+		{"let f = fn() { y += 1 }; f()", "assignment to undeclared variable: y"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let x = 1; x /= 0", "division by zero: 1 / 0"},
+	}
+	for _, tt := range errors {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestIndexAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let a = [1, 2, 3]; a[0] = 5; a[0]", 5},
+		{"let a = [1, 2, 3]; a[2] = 5", 5},
+		{"let a = [1, 2, 3]; a[1] += 10; a[1]", 12},
+		{"let a = [0, 0, 0]; for (let i = 0; i < 3; i += 1) { a[i] = i * 2 }; a[2]", 4},
+		// the current element is read before the value is evaluated
+		{"let a = [1]; let f = fn() { a[0] = 10; 1 }; a[0] += f(); a[0]", 2},
+		{"let x = 1; let f = fn() { x = 10; 1 }; x += f(); x", 2},
+		{"let a = [[1, 2], [3, 4]]; a[1][0] = 7; a[1][0]", 7},
+		{`let h = {"a": 1}; h["a"] = 2; h["a"]`, 2},
+		{`let h = {"a": 1}; h["b"] = 3; h["b"] + h["a"]`, 4},
+		{`let h = {"n": 1}; h["n"] *= 5; h["n"]`, 5},
+		{`let h = {}; h[true] = 1; h[1] = 2; h[true] + h[1]`, 3},
+		// arrays and hashes are modified in place, so the change is seen
+		// through every reference to them
+		{"let a = [1]; let b = a; b[0] = 2; a[0]", 2},
+		{`let h = {}; let set = fn(k, v) { h[k] = v }; set("x", 9); h["x"]`, 9},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, int64(tt.expected.(int)))
+	}
+
+	errors := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"let a = [1, 2, 3]; a[3] = 1", "index out of range: 3, length 3"},
+		{"let a = []; a[0] = 1", "index out of range: 0, length 0"},
+		{"let a = [1, 2, 3]; a[-1] = 1", "index out of range: -1, length 3"},
+		{`let a = [1, 2, 3]; a["x"] = 1`, "array index must be INTEGER, got STRING"},
+		{"let a = [1, 2, 3]; a[3] += 1", "type mismatch: NULL + INTEGER"},
+		{`let h = {}; h[fn(x) { x }] = 1`, "unusable as hash key: FUNCTION"},
+		{`let s = "abc"; s[0] = "x"`, "index assignment not supported: STRING"},
+		{"b[0] = 1", "identifier not found: b"},
+	}
+	for _, tt := range errors {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 10) { i += 1 }; i", 10},
+		{"let i = 0; while (i < 10) { i += 1; if (i == 5) { break } }; i", 5},
+		{"let i = 0; let n = 0; while (i < 10) { i += 1; if (i % 2 == 0) { continue } n += 1 }; n", 5},
+		{"let n = 0; for (let i = 0; i < 5; i += 1) { n += i }; n", 10},
+		{"let n = 0; for (let i = 0; ; i += 1) { if (i > 3) { break } n += 1 }; n", 4},
+		{"let n = 0; for (let i = 0; i < 5; i += 1) { if (i == 2) { continue } n += i }; n", 8},
+		{"let n = 0; for (x in [1, 2, 3]) { n += x }; n", 6},
+		{`let n = 0; for (k in {"a": 1, "b": 2}) { n += 1 }; n`, 2},
+		{`let h = {"a": 1, "b": 2}; let n = 0; for (k in h) { n += h[k] }; n`, 3},
+		{"let n = 0; for (x in []) { n += 1 }; n", 0},
+		// the nearest loop is the one that is left
+		{`let n = 0;
+		  for (let i = 0; i < 3; i += 1) {
+		    for (let j = 0; j < 3; j += 1) { if (j == 1) { break } n += 1 }
+		  }; n`, 3},
+		// return leaves the loop and the function
+		{"let f = fn() { let i = 0; while (true) { i += 1; if (i == 7) { return i } } }; f()", 7},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10 } } }; f()", 20},
+		// the loop body can change the array without affecting the iteration
+		{"let a = [1, 2, 3]; let n = 0; for (x in a) { a[2] = 0; n += x }; n", 6},
+		// each iteration has its own binding of the loop variable
+		{"let fs = []; for (x in [1, 2, 3]) { fs = push(fs, fn() { x }) }; fs[0]() + fs[2]()", 4},
+		// the init of a for loop is scoped to the loop
+		{"let i = 100; for (let i = 0; i < 3; i += 1) { }; i", 100},
+		{"let s = \"\"; for (c in \"abc\") { s = c + s }; s", "cba"},
+		// a break or continue in the value of a let or an assignment leaves it
+		{"let r = 0; for (x in [1,2,3]) { let y = if (x == 2) { continue } else { x }; r += y }; r", 4},
+		{"let r = 0; for (x in [1,2,3]) { r = if (x == 3) { break } else { r + x } }; r", 3},
+		{"let f = fn() { for (x in [1, 2]) { let y = if (x == 2) { return 20 } else { x } } }; f()", 20},
+		{"while (false) { }", nil},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+
+	errors := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"for (x in 5) { }", "not iterable: INTEGER"},
+		{"while (x) { }", "identifier not found: x"},
+		{"let i = 0; while (true) { i += 1; if (i == 3) { i + true } }", "type mismatch: INTEGER + BOOLEAN"},
+		{"for (let i = 0; i < 3; i = i + true) { }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+	for _, tt := range errors {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		// deep enough to overflow the Go stack without tail calls
+		{`let count = fn(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } };
+		  count(1000000, 0)`, 1000000},
+		{`let count = fn(n) { if (n == 0) { return 0; } return count(n - 1); };
+		  count(100000)`, 0},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+		  let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+		  if (even(100000)) { 1 } else { 0 }`, 1},
+		{`let loop = fn(n) { while (true) { if (n == 0) { return 7; } return loop(n - 1); } };
+		  loop(100000)`, 7},
+		{`let reduce = fn(arr, initial, f) {
+		    let iter = fn(i, result) {
+		      if (i == len(arr)) { return result; }
+		      iter(i + 1, f(result, arr[i]))
+		    };
+		    iter(0, initial)
+		  };
+		  let map = fn(arr, f) { reduce(arr, [], fn(acc, x) { push(acc, f(x)) }) };
+		  let build = fn(n, acc) { if (n == 0) { return acc; } build(n - 1, push(acc, n)) };
+		  reduce(map(build(10000, []), fn(x) { x * 2 }), 0, fn(a, b) { a + b })`, 100010000},
+		// a call that is not in tail position still returns to its caller
+		{"let f = fn(x) { x + 1 }; let g = fn(x) { f(x) * 2 }; g(1)", 4},
+		{"let f = fn(x) { x }; return f(5); 10", 5},
+		{"let f = fn() { len([1, 2, 3]) }; f()", 3},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+
+	evaluated := testEval("let f = fn() { g() };\nlet g = fn() { len(1) };\nf()")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Pos.String() != "2:19" {
+		t.Errorf("error in tail call has wrong position. expected=%q, got=%q", "2:19", errObj.Pos)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	input := `let f = fn(n) { 1 + f(n + 1) };
+f(0)`
+
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "maximum recursion depth exceeded" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+	if len(errObj.Stack) != DefaultMaxDepth+1 {
+		t.Fatalf("wrong stack depth. expected=%d, got=%d", DefaultMaxDepth+1, len(errObj.Stack))
+	}
+	if errObj.Stack[0].String() != "f at 2:2" {
+		t.Errorf("wrong outermost frame. expected=%q, got=%q", "f at 2:2", errObj.Stack[0])
+	}
+	if errObj.Stack[1].String() != "f at 1:22" {
+		t.Errorf("wrong inner frame. expected=%q, got=%q", "f at 1:22", errObj.Stack[1])
+	}
+
+	evaluated = testEvalWith(input, WithMaxDepth(50))
+	errObj, ok = evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if len(errObj.Stack) != 51 {
+		t.Errorf("wrong stack depth. expected=%d, got=%d", 51, len(errObj.Stack))
+	}
+
+	tests := []struct {
+		input    string
+		opts     []Option
+		expected int64
+	}{
+		// recursion within the limit is unaffected
+		{"let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(50)", []Option{WithMaxDepth(51)}, 1275},
+		// tail calls do not count towards the depth
+		{"let count = fn(n) { if (n == 0) { 0 } else { count(n - 1) } }; count(1000)", []Option{WithMaxDepth(5)}, 0},
+		{"let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(20000)", []Option{WithMaxDepth(0)}, 200010000},
+		// the depth is back to zero once a call has returned
+		{"let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(40) + sum(40)", []Option{WithMaxDepth(41)}, 1640},
+	}
+	for _, tt := range tests {
+		evaluated := testEvalWith(tt.input, tt.opts...)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	runaway := []string{
+		"while (true) { }",
+		"for (;;) { }",
+		"let f = fn() { f() }; f()",
+		"let f = fn(n) { let g = fn() { n }; g(); f(n + 1) }; f(0)",
+	}
+
+	for _, input := range runaway {
+		program := parser.New(lexer.New(input)).ParseProgram()
+
+		evaluated := EvalProgram(context.Background(), program, WithStepBudget(1000))
+		testStopped(t, input, evaluated, ErrBudgetExceeded)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		evaluated = EvalProgram(ctx, program)
+		cancel()
+		testStopped(t, input, evaluated, context.DeadlineExceeded)
+
+		ctx, cancel = context.WithCancel(context.Background())
+		cancel()
+		evaluated = EvalProgram(ctx, program)
+		testStopped(t, input, evaluated, context.Canceled)
+	}
+
+	// the budget is for each evaluation, not for the Evaluator
+	e := New(WithStepBudget(10))
+	program := parser.New(lexer.New("let n = 0; for (x in [1, 2, 3, 4, 5]) { n += x }; n")).ParseProgram()
+	for i := 0; i < 3; i++ {
+		testIntegerObject(t, e.Eval(program, object.NewEnvironment()), 15)
+	}
+
+	// a script that stays within its budget is not affected by it
+	testIntegerObject(t, testEvalWith("let f = fn(x) { x * 2 }; f(f(f(1)))", WithStepBudget(3)), 8)
+	evaluated := testEvalWith("let f = fn(x) { x * 2 }; f(f(f(1)))", WithStepBudget(2))
+	testStopped(t, "three calls with a budget of two", evaluated, ErrBudgetExceeded)
+}
+
+func testStopped(t *testing.T, input string, obj object.Object, cause error) {
+	t.Helper()
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("%q: no error object returned. got=%T(%+v)", input, obj, obj)
+		return
+	}
+	if !errors.Is(errObj.Cause, cause) {
+		t.Errorf("%q: wrong cause. expected=%v, got=%v", input, cause, errObj.Cause)
+	}
+	if errObj.Message != "evaluation stopped: "+cause.Error() {
+		t.Errorf("%q: wrong error message. got=%q", input, errObj.Message)
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	runaway := []string{
+		"let a = []; while (true) { a = push(a, 1) }",
+		`let s = "x"; while (true) { s = s + s }`,
+		`let s = "x"; while (true) { s = "${s}${s}" }`,
+		"let a = []; let i = 0; while (true) { a = push(a, i); i += 1 }",
+		"let h = {}; let i = 0; while (true) { h[i] = i; i += 1 }",
+		"let f = fn(a) { f([a, a]) }; f([])",
+		"let n = 3; while (true) { n = n * n }",
+		"let n = 1; while (true) { n = n << 1000 }",
+		"let n = 1; while (true) { n = -(n << 1000) }",
+		"while (true) { let n = 1 << 16000000 }",
+	}
+	for _, input := range runaway {
+		evaluated := testEvalWith(input, WithMemoryLimit(1<<20))
+		testStopped(t, input, evaluated, ErrMemoryLimitExceeded)
+	}
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		// values that already exist are not counted again
+		{`let a = ["a long string that is only allocated once"];
+		  let n = 0; for (let i = 0; i < 1000; i += 1) { n += len(first(a)) + len(last(a)) }; n`, 82000},
+		{`let h = {"k": 1}; for (let i = 0; i < 1000; i += 1) { h["k"] = i }; h["k"]`, 999},
+		{"let a = [1, 2, 3]; for (let i = 0; i < 1000; i += 1) { a[0] = i }; a[0]", 999},
+	}
+	for _, tt := range tests {
+		evaluated := testEvalWith(tt.input, WithMemoryLimit(1024))
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+
+	// the limit is for each evaluation, not for the Evaluator
+	e := New(WithMemoryLimit(1024))
+	program := parser.New(lexer.New(`let s = ""; for (let i = 0; i < 20; i += 1) { s += "x" }; len(s)`)).ParseProgram()
+	for i := 0; i < 3; i++ {
+		testIntegerObject(t, e.Eval(program, object.NewEnvironment()), 20)
+	}
+}
+
+func TestErrorStack(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedStack []string
+	}{
+		{"1 + true", nil},
+		{`let helper = fn(x) { x + true };
+let main = fn() { let y = helper(1); y };
+main()`, []string{"main at 3:5", "helper at 2:33"}},
+		// a tail call replaces the frame of its caller
+		{`let helper = fn(x) { x + true };
+let main = fn() { helper(1) };
+main()`, []string{"helper at 2:25"}},
+		{`let apply = fn(f) { let r = f(); r };
+apply(fn() { len(1) + 1 })`, []string{"apply at 2:6", "<anonymous> at 1:30"}},
+		{`let f = fn(n) { if (n == 0) { 1 + true } else { 1 + f(n - 1) } };
+f(2)`, []string{"f at 2:2", "f at 1:54", "f at 1:54"}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		stack := []string{}
+		for _, frame := range errObj.Stack {
+			stack = append(stack, frame.String())
+		}
+		if strings.Join(stack, ", ") != strings.Join(tt.expectedStack, ", ") {
+			t.Errorf("wrong stack for %q. expected=%v, got=%v", tt.input, tt.expectedStack, stack)
+		}
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { throw "bad input" } catch (e) { e["message"] }`, "bad input"},
+		{`try { 1 + true } catch (e) { e["message"] }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { throw 42 } catch (e) { e["value"] }`, 42},
+		{`try { throw 42 } catch (e) { e["message"] }`, "42"},
+		{`try { 1 + true } catch (e) { e["value"] }`, nil},
+		{`try { throw {"message": "bad", "field": "age"} } catch (e) { e["message"] + " " + e["value"]["field"] }`, "bad age"},
+		// errors raised in called functions are caught, with the calls that led to them
+		{`let check = fn(x) { if (x < 0) { throw "negative" } x };
+		  try { check(-1) } catch (e) { e["message"] }`, "negative"},
+		{`let inner = fn() { let r = 1 + true; r };
+		  let outer = fn() { let r = inner(); r };
+		  try { outer() } catch (e) { len(e["stack"]) }`, 2},
+		{`let inner = fn() { let r = 1 + true; r };
+		  let outer = fn() { let r = inner(); r };
+		  try { outer() } catch (e) { e["stack"][1] }`, "inner at 2:37"},
+		// a call returned from the try block is made inside it
+		{`let fail = fn() { throw "failed" };
+		  let f = fn() { try { return fail(); } catch (e) { return "caught " + e["message"]; } };
+		  f()`, "caught failed"},
+		// collecting several failures instead of stopping at the first
+		{`let errors = [];
+		  for (x in [1, -2, 3, -4]) {
+		    try { if (x < 0) { throw "negative: ${x}" } } catch (e) { errors = push(errors, e["message"]) }
+		  };
+		  errors[0] + ", " + errors[1]`, "negative: -2, negative: -4"},
+		// a caught error can be thrown again
+		{`try { try { throw "inner" } catch (e) { throw e } } catch (e) { e["message"] }`, "inner"},
+		{`try { try { throw "inner" } catch (e) { throw "outer" } } catch (e) { e["message"] }`, "outer"},
+		// finally always runs, but only changes the result when leaving
+		{`let log = []; try { log = push(log, "try") } finally { log = push(log, "finally") }; log[1]`, "finally"},
+		{`let log = []; try { throw "x" } catch (e) { log = push(log, "catch") } finally { log = push(log, "finally") }; len(log)`, 2},
+		{`try { 1 } finally { 2 }`, 1},
+		{`let f = fn() { try { return 1; } finally { return 2; } }; f()`, 2},
+		{`let log = []; try { try { throw "x" } finally { log = push(log, "finally") } } catch (e) { log[0] + " " + e["message"] }`, "finally x"},
+		{`let n = 0; while (true) { try { break } finally { n = 5 } }; n`, 5},
+		{`let f = fn() { try { throw "x" } catch (e) { return 3; } finally { } }; f()`, 3},
+		// the catch variable is scoped to the catch block
+		{`let e = 1; try { throw "x" } catch (e) { e }; e`, 1},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("%q: object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+
+	errors := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`throw "uncaught"`, "uncaught"},
+		{`let f = fn() { throw {"message": "from hash"} }; f()`, "from hash"},
+		{`try { throw "x" } finally { 1 }`, "x"},
+		{`try { 1 } finally { throw "from finally" }`, "from finally"},
+		{`try { throw "x" } catch (e) { 1 + true }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`throw y`, "identifier not found: y"},
+	}
+	for _, tt := range errors {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+
+	// errors that stop the evaluation cannot be caught
+	evaluated := testEvalWith(`let n = 0; try { while (true) { n += 1 } } catch (e) { n }`, WithStepBudget(100))
+	testStopped(t, "caught budget", evaluated, ErrBudgetExceeded)
+}
+
+func TestIntegerOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"10 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"7 ** 0", 1},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"~5", -6},
+		{"1 << 4", 16},
+		{"-16 >> 2", -4},
+		{"1 + 2 * 3 % 4", 3},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestFloatOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"7.5 % 2", 1.5},
+		{"2 ** 0.5 ** 2", 1.189207115002721},
+		{"4.0 ** 0.5", 2},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestOperatorErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"2 ** -1", "negative exponent: 2 ** -1"},
+		{"1 << -1", "negative shift count: 1 << -1"},
+		{"~true", "unknown operator: ~BOOLEAN"},
+		{"~1.5", "unknown operator: ~FLOAT"},
+		{"1.5 & 1", "unknown operator: FLOAT & INTEGER"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestDivisionByZero(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 / 0", "division by zero: 5 / 0"},
+		{"5 % 0", "division by zero: 5 % 0"},
+		{"let f = fn(x) { 10 / x }; f(0)", "division by zero: 10 / 0"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestCheckedArithmetic(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"9223372036854775807 + 1", "integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 2", "integer overflow: -9223372036854775807 - 2"},
+		{"4611686018427387904 * 2", "integer overflow: 4611686018427387904 * 2"},
+		{"(-9223372036854775807 - 1) / -1", "integer overflow: -9223372036854775808 / -1"},
+		{"-(-9223372036854775807 - 1)", "integer overflow: -(-9223372036854775808)"},
+		{"2 ** 63", "integer overflow: 2 ** 63"},
+		{"1 << 63", "integer overflow: 1 << 63"},
+		{"3 << 64", "integer overflow: 3 << 64"},
+	}
+	for _, tt := range tests {
+		evaluated := testEvalWith(tt.input, WithCheckedArithmetic())
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+
+	inRange := []struct {
+		input    string
+		expected int64
+	}{
+		{"9223372036854775806 + 1", 9223372036854775807},
+		{"-9223372036854775807 - 1", -9223372036854775808},
+		{"-4611686018427387904 * 2", -9223372036854775808},
+		{"2 ** 62", 4611686018427387904},
+		{"-1 << 63", -9223372036854775808},
+	}
+	for _, tt := range inRange {
+		evaluated := testEvalWith(tt.input, WithCheckedArithmetic())
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+
+	// without checking, overflow promotes to a BigInt
+	testBigIntObject(t, testEval("9223372036854775807 + 1"), "9223372036854775808")
+}
+
+func testBigIntObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.BigInt)
+	if !ok {
+		t.Errorf("object is not BigInt. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value.String() != expected {
+		t.Errorf("object has wrong value. got=%s, want=%s",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"9223372036854775808", "9223372036854775808"},
+		{"-9223372036854775809", "-9223372036854775809"},
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4611686018427387904 * 2", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"2 ** 100", "1267650600228229401496703205376"},
+		{"1 << 64", "18446744073709551616"},
+		{"99999999999999999999 * 99999999999999999999", "9999999999999999999800000000000000000001"},
+		{"-99999999999999999999 / 7", "-14285714285714285714"},
+		{"-99999999999999999999 % 7", -1},
+		{"(2 ** 100) >> 99", 2},
+		{"(2 ** 100) >> 1000", 0},
+		{"-(2 ** 100) >> 1000", -1},
+		{"~(2 ** 64)", "-18446744073709551617"},
+		{"(2 ** 64 + 5) & 7", 5},
+		{"(2 ** 64) | 1", "18446744073709551617"},
+		{"(2 ** 64) ^ (2 ** 64)", 0},
+		// results that fit are Integers again
+		{"9223372036854775808 - 1", 9223372036854775807},
+		{"(2 ** 100) / (2 ** 98)", 4},
+		{"18446744073709551616 < 18446744073709551617", true},
+		{"18446744073709551616 >= 1", true},
+		{"18446744073709551616 == 2 ** 64", true},
+		{"18446744073709551616 != 2 ** 64", false},
+		{"9223372036854775808 == 9223372036854775807", false},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case string:
+			testBigIntObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+
+	testFloatObject(t, testEval("18446744073709551616 * 0.5"), 9223372036854775808)
+	testBooleanObject(t, testEval("18446744073709551616 > 1.5"), true)
+
+	errors := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"18446744073709551616 / 0", "division by zero: 18446744073709551616 / 0"},
+		{"18446744073709551616 % 0", "division by zero: 18446744073709551616 % 0"},
+		{"2 ** 18446744073709551616", "exponent too large: 2 ** 18446744073709551616"},
+		{"18446744073709551616 ** -1", "negative exponent: 18446744073709551616 ** -1"},
+		{"1 << 18446744073709551616", "shift count too large: 1 << 18446744073709551616"},
+	}
+	for _, tt := range errors {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestScopes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; let f = fn() { let y = x; let x = 2; y * 10 + x }; f()", 12},
+		{"let counter = fn() { let n = 0; fn() { n += 1; n } }; let c = counter(); c(); c()", 2},
+		{"let h = fn() { let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(10) }; if (h()) { 1 } else { 0 }", 1},
+		{"let f = fn(a, b) { let c = a; for (x in [b]) { let d = x; c += d }; c }; f(1, 2)", 3},
+		{"let f = fn() { try { throw 1 } catch (e) { let d = e[\"value\"]; d + 1 } }; f()", 2},
+		{"let f = fn() { let s = 0; for (let i = 0; i < 4; i += 1) { let j = i * 2; s += j }; s }; f()", 12},
+		{"let f = fn() { if (false) { let y = 1 }; y }; f()", "identifier not found: y"},
+		// a let in a branch that is not taken leaves its variable unbound, the
+		// one of the same name further out is not used in its place
+		{"let x = 5; let f = fn(c) { if (c) { let x = 1 }; x }; f(true)", 1},
+		{"let x = 5; let f = fn(c) { if (c) { let x = 1 }; x }; f(false)", "identifier not found: x"},
+		{"let x = 5; let f = fn(c) { if (c) { let x = 1 }; x = 2 }; f(false)", "assignment to undeclared variable: x"},
+		{"let f = fn(c) { if (c) { let len = 1 }; len([1]) }; f(false)", 1},
+		{"x; let x = 1", "use of x before its declaration"},
+		{"let f = fn() { let y = x + 1; let x = 2; y }; f()", "use of x before its declaration"},
+		{"let f = fn() { x = 1; let x = 2; x }; f()", "use of x before its declaration"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+
+	// a program with an error found by the resolver is not run at all
+	evaluated := testEval("let a = [];\nlet f = fn() { a[0] = 1 };\nf();\nlet b = c;\nlet c = 1")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Pos.String() != "4:9" {
+		t.Errorf("expected an error at 4:9. got=%T(%+v)", evaluated, evaluated)
+	}
+}
+
+func TestGlobalsOfEarlierPrograms(t *testing.T) {
+	tests := []struct {
+		programs []string
+		expected interface{}
+	}{
+		{[]string{"let x = 1", "let x = x + 1; x"}, 2},
+		{[]string{"let count = 0", "let count = count + 1", "let count = count + 1; count"}, 2},
+		{[]string{"let n = 1", "let f = fn() { n }; let n = 5; f()"}, 5},
+		// only a global an earlier program bound can be used before it is
+		// bound again, and only at the top of the program
+		{[]string{"let a = 1", "b; let b = 2"}, "use of b before its declaration"},
+		{[]string{"let x = 1", "let f = fn() { let y = x; let x = 2; y }; f()"}, "use of x before its declaration"},
+	}
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		e := New()
+		var evaluated object.Object
+		for _, input := range tt.programs {
+			evaluated = e.Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+		}
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%q: no error object returned. got=%T(%+v)", tt.programs, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.programs, expected, errObj.Message)
+			}
+		}
+	}
+}
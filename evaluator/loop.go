@@ -0,0 +1,131 @@
+package evaluator
+
+import (
+	"github.com/shanehowearth/interpreter/ast"
+	"github.com/shanehowearth/interpreter/object"
+)
+
+// loopExit reports whether the result of a loop body ends the loop, and what
+// the loop then evaluates to. A return or an error propagates out of the loop.
+func loopExit(result object.Object) (bool, object.Object) {
+	switch result.(type) {
+	case *object.Break:
+		return true, NULL
+	case *object.ReturnValue, *object.Error:
+		return true, result
+	default:
+		return false, nil
+	}
+}
+
+func (e *Evaluator) evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		if err := e.step(); err != nil {
+			return err
+		}
+		condition := e.eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		if exit, result := loopExit(e.eval(node.Body, env)); exit {
+			return result
+		}
+	}
+}
+
+// evalForStatement evaluates a C style for loop, the variables declared by
+// its init are scoped to the loop
+func (e *Evaluator) evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	loopEnv := object.NewFrame(node.Slots, env)
+	if node.Init != nil {
+		if init := e.eval(node.Init, loopEnv); isError(init) {
+			return init
+		}
+	}
+
+	for {
+		if err := e.step(); err != nil {
+			return err
+		}
+		if node.Condition != nil {
+			condition := e.eval(node.Condition, loopEnv)
+			if isError(condition) {
+				return condition
+			}
+			if !isTruthy(condition) {
+				return NULL
+			}
+		}
+
+		if exit, result := loopExit(e.eval(node.Body, loopEnv)); exit {
+			return result
+		}
+
+		if node.Update != nil {
+			if update := e.eval(node.Update, loopEnv); isError(update) {
+				return update
+			}
+		}
+	}
+}
+
+// evalForInStatement binds the loop variable in a new scope for every
+// iteration, so closures created in the body each see their own value
+func (e *Evaluator) evalForInStatement(node *ast.ForInStatement, env *object.Environment) object.Object {
+	iterable := e.eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	values, err := e.iterate(iterable)
+	if err != nil {
+		return withPos(err, node.Iterable)
+	}
+
+	for _, value := range values {
+		if err := e.step(); err != nil {
+			return err
+		}
+		iterEnv := object.NewFrame(node.Slots, env)
+		bind(node.Variable, iterEnv, value)
+
+		if exit, result := loopExit(e.eval(node.Body, iterEnv)); exit {
+			return result
+		}
+	}
+	return NULL
+}
+
+// iterate returns the values a for in loop visits, taken before the loop
+// starts so that changing the iterable in the body does not affect the loop
+func (e *Evaluator) iterate(iterable object.Object) ([]object.Object, object.Object) {
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		values := make([]object.Object, len(iterable.Elements))
+		copy(values, iterable.Elements)
+		return values, nil
+	case *object.String:
+		values := []object.Object{}
+		for _, ch := range iterable.Value {
+			value := e.allocate(&object.String{Value: string(ch)})
+			if isError(value) {
+				return nil, value
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case *object.Hash:
+		// the keys are visited in the order they were added
+		values := make([]object.Object, len(iterable.Pairs))
+		for idx, pair := range iterable.Pairs {
+			values[idx] = pair.Key
+		}
+		return values, nil
+	default:
+		return nil, newError("not iterable: %s", iterable.Type())
+	}
+}
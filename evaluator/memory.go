@@ -0,0 +1,84 @@
+package evaluator
+
+import (
+	"errors"
+
+	"github.com/shanehowearth/interpreter/object"
+)
+
+// ErrMemoryLimitExceeded - the Cause of the error evaluation stops with once
+// the memory limit has been reached
+var ErrMemoryLimitExceeded = errors.New("memory limit exceeded")
+
+// Approximate sizes in bytes of what is counted towards the memory limit
+const (
+	stringSize  = 16 // a string header, the bytes are counted separately
+	arraySize   = 24 // a slice header
+	elementSize = 16 // an interface value in an array
+	hashSize    = 48 // a map header
+	pairSize    = 64 // a HashKey and a HashPair in a map, with its overhead
+	bigIntSize  = 32 // a big.Int, its words are counted separately
+)
+
+// WithMemoryLimit - the approximate number of bytes an evaluation may
+// allocate for strings, arrays, hashes and big integers. Memory is counted when it is
+// allocated and not given back when a value is no longer used, so the limit
+// bounds the total allocated over the evaluation. A limit of 0 or less is
+// unlimited.
+func WithMemoryLimit(bytes int64) Option {
+	return func(e *Evaluator) {
+		e.maxMemory = bytes
+	}
+}
+
+// sizeOf approximates the memory used by obj itself, values held in an array
+// or a hash are counted when they are created
+func sizeOf(obj object.Object) int64 {
+	switch obj := obj.(type) {
+	case *object.String:
+		return stringSize + int64(len(obj.Value))
+	case *object.Array:
+		return arraySize + elementSize*int64(len(obj.Elements))
+	case *object.Hash:
+		return hashSize + pairSize*int64(len(obj.Pairs))
+	case *object.BigInt:
+		return bigIntSize + int64(obj.Value.BitLen()+7)/8
+	default:
+		return 0
+	}
+}
+
+// allocate counts obj, a newly created value, towards the memory limit and
+// returns it, or the error evaluation stops with when the limit is exceeded
+func (e *Evaluator) allocate(obj object.Object) object.Object {
+	if err := e.charge(sizeOf(obj)); err != nil {
+		return err
+	}
+	return obj
+}
+
+// charge counts bytes towards the memory limit
+func (e *Evaluator) charge(bytes int64) *object.Error {
+	e.allocated += bytes
+	if e.maxMemory > 0 && e.allocated > e.maxMemory {
+		return stopped(ErrMemoryLimitExceeded)
+	}
+	return nil
+}
+
+// allocateBuiltinResult counts the result of a builtin, unless it is a value
+// that already existed, i.e. one of the arguments, or the first or last
+// element of an array argument as returned by first and last
+func (e *Evaluator) allocateBuiltinResult(result object.Object, args []object.Object) object.Object {
+	for _, arg := range args {
+		if result == arg {
+			return result
+		}
+		if arr, ok := arg.(*object.Array); ok && len(arr.Elements) > 0 {
+			if result == arr.Elements[0] || result == arr.Elements[len(arr.Elements)-1] {
+				return result
+			}
+		}
+	}
+	return e.allocate(result)
+}
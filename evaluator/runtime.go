@@ -0,0 +1,108 @@
+package evaluator
+
+import (
+	"context"
+
+	"github.com/shanehowearth/interpreter/object"
+)
+
+// The operations below are those of the evaluator that the bytecode vm runs
+// programs with, so that a program means the same whichever of the two runs
+// it. Errors are returned without a position, the caller knows where the
+// operation is in the source.
+
+// Start - begins an evaluation that is not made by EvalContext, with the step
+// budget and the memory limit reset, stopping once ctx is done
+func (e *Evaluator) Start(ctx context.Context) {
+	e.ctx = ctx
+	e.steps = 0
+	e.allocated = 0
+}
+
+// MaxDepth - the number of nested calls allowed, 0 or less for no limit
+func (e *Evaluator) MaxDepth() int {
+	return e.maxDepth
+}
+
+// Step - counts a call or a loop iteration, returning the error evaluation
+// stops with when the budget is used up or the context is done
+func (e *Evaluator) Step() *object.Error {
+	return e.step()
+}
+
+// Allocate - counts obj, a newly created string, array, hash or big integer,
+// towards the memory limit
+func (e *Evaluator) Allocate(obj object.Object) object.Object {
+	return e.allocate(obj)
+}
+
+// Prefix - applies a prefix operator such as - or !
+func (e *Evaluator) Prefix(operator string, right object.Object) object.Object {
+	return e.evalPrefixExpression(operator, right)
+}
+
+// Infix - applies an infix operator, other than && and || which only
+// evaluate their right operand when it is needed
+func (e *Evaluator) Infix(operator string, left, right object.Object) object.Object {
+	return e.evalInfixExpression(operator, left, right)
+}
+
+// Index - the element of an array or a hash at index
+func (e *Evaluator) Index(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// SetIndex - sets the element of an array or a hash at index to val
+func (e *Evaluator) SetIndex(left, index, val object.Object) object.Object {
+	return e.setIndex(left, index, val)
+}
+
+// Hash - a hash of pairs, as made by a hash literal
+func (e *Evaluator) Hash(pairs []object.HashPair) object.Object {
+	hash := object.NewHash(len(pairs))
+	for _, pair := range pairs {
+		key, ok := pair.Key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", pair.Key.Type())
+		}
+		hash.Set(key, pair.Value)
+	}
+	return e.allocate(hash)
+}
+
+// Interpolate - the string of an interpolated string literal with values for
+// its parts
+func (e *Evaluator) Interpolate(values []object.Object) object.Object {
+	return e.interpolate(values)
+}
+
+// CallBuiltin -
+func (e *Evaluator) CallBuiltin(fn *object.Builtin, args []object.Object) object.Object {
+	return e.allocateBuiltinResult(fn.Fn(args...), args)
+}
+
+// Iterate - the values a for in loop over iterable visits
+func (e *Evaluator) Iterate(iterable object.Object) ([]object.Object, object.Object) {
+	return e.iterate(iterable)
+}
+
+// Throw - the error a throw statement raises for val
+func (e *Evaluator) Throw(val object.Object) *object.Error {
+	return thrown(val)
+}
+
+// Catch - the value a catch block binds err to
+func (e *Evaluator) Catch(err *object.Error) object.Object {
+	return e.caughtError(err)
+}
+
+// Builtin - the builtin function called name
+func Builtin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// IsTruthy - whether a condition with the value obj holds
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
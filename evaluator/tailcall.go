@@ -0,0 +1,80 @@
+package evaluator
+
+import (
+	"github.com/shanehowearth/interpreter/ast"
+	"github.com/shanehowearth/interpreter/object"
+)
+
+// tailCall is a call in tail position that has not been made yet. It is
+// returned to applyFunction, which makes the call in a loop rather than
+// recursing, so that tail recursive functions run in constant Go stack.
+type tailCall struct {
+	fn   object.Object
+	args []object.Object
+	node *ast.CallExpression
+}
+
+// Type -
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+
+// Inspect -
+func (tc *tailCall) Inspect() string { return "tail call " + tc.node.String() }
+
+// Equals -
+func (tc *tailCall) Equals(other object.Object) bool {
+	o, ok := other.(*tailCall)
+	return ok && o == tc
+}
+
+// evalTail evaluates an expression in tail position, a call there is returned
+// as a tailCall instead of being made
+func (e *Evaluator) evalTail(node ast.Expression, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.CallExpression:
+		function, args, err := e.evalCall(node, env)
+		if err != nil {
+			return err
+		}
+		return &tailCall{fn: function, args: args, node: node}
+	case *ast.IfExpression:
+		condition := e.eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			return e.evalTailBlock(node.Consequence, env)
+		} else if node.Alternative != nil {
+			return e.evalTailBlock(node.Alternative, env)
+		}
+		return NULL
+	default:
+		return e.eval(node, env)
+	}
+}
+
+// evalTailBlock evaluates a block in tail position, such as a function body,
+// its last expression is in tail position too
+func (e *Evaluator) evalTailBlock(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+	for idx, statement := range block.Statements {
+		if stmt, ok := statement.(*ast.ExpressionStatement); ok && idx == len(block.Statements)-1 {
+			return e.evalTail(stmt.Expression, env)
+		}
+		result = e.eval(statement, env)
+		if isInterrupt(result) {
+			return result
+		}
+	}
+	return result
+}
+
+// runTailCalls makes the calls result asks for until one returns a value
+func (e *Evaluator) runTailCalls(result object.Object) object.Object {
+	for {
+		call, ok := result.(*tailCall)
+		if !ok {
+			return result
+		}
+		result = withPos(e.callFunction(call.fn, call.args, call.node), call.node)
+	}
+}
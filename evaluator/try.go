@@ -0,0 +1,124 @@
+package evaluator
+
+import (
+	"github.com/shanehowearth/interpreter/ast"
+	"github.com/shanehowearth/interpreter/object"
+)
+
+// evalThrowStatement raises the value as an error. A string is the message of
+// the error, as is the "message" of a hash, such as the one bound by catch, so
+// that a caught error can be thrown again.
+func (e *Evaluator) evalThrowStatement(node *ast.ThrowStatement, env *object.Environment) object.Object {
+	val := e.eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	return withPos(thrown(val), node)
+}
+
+// thrown is the error throw raises for val
+func thrown(val object.Object) *object.Error {
+	err := newError("%s", thrownMessage(val))
+	err.Value = val
+	return err
+}
+
+func thrownMessage(val object.Object) string {
+	switch val := val.(type) {
+	case *object.String:
+		return val.Value
+	case *object.Hash:
+		key := &object.String{Value: "message"}
+		if pair, ok := val.Get(key); ok {
+			if message, ok := pair.Value.(*object.String); ok {
+				return message.Value
+			}
+		}
+	}
+	return val.Inspect()
+}
+
+// evalTryExpression evaluates the try block, and the catch block if the try
+// block raised an error. The finally block is always evaluated, but only
+// changes the result when it raises an error or leaves with return, break or
+// continue. An error that stopped the evaluation, such as a cancelled
+// context, cannot be caught.
+func (e *Evaluator) evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	result := e.completeTailCall(e.eval(node.Block, env))
+
+	if err, ok := result.(*object.Error); ok {
+		if err.Cause != nil {
+			return err
+		}
+		if node.Catch != nil {
+			caught := e.caughtError(err)
+			if isError(caught) {
+				return caught
+			}
+			catchEnv := object.NewFrame(node.CatchSlots, env)
+			bind(node.CatchParam, catchEnv, caught)
+			result = e.completeTailCall(e.eval(node.Catch, catchEnv))
+		}
+	}
+
+	if node.Finally != nil {
+		switch final := e.eval(node.Finally, env).(type) {
+		case *object.Error, *object.ReturnValue, *object.Break, *object.Continue:
+			return final
+		}
+	}
+	return result
+}
+
+// completeTailCall makes a call returned by result, so that it is made
+// inside the try, where its errors are caught, rather than after it
+func (e *Evaluator) completeTailCall(result object.Object) object.Object {
+	returnValue, ok := result.(*object.ReturnValue)
+	if !ok {
+		return result
+	}
+	if _, ok := returnValue.Value.(*tailCall); !ok {
+		return result
+	}
+
+	value := e.runTailCalls(returnValue.Value)
+	if isError(value) {
+		return value
+	}
+	return &object.ReturnValue{Value: value}
+}
+
+// caughtError is the value err is bound to in a catch block, a hash of its
+// "message", the "stack" of calls it was raised in, and the "value" it was
+// thrown with, which is null for errors raised by the interpreter
+func (e *Evaluator) caughtError(err *object.Error) object.Object {
+	stack := err.Stack
+	if stack == nil {
+		stack = e.stack()
+	}
+	frames := make([]object.Object, len(stack))
+	for idx, frame := range stack {
+		frames[idx] = &object.String{Value: frame.String()}
+	}
+
+	value := err.Value
+	if value == nil {
+		value = NULL
+	}
+
+	fields := []struct {
+		name  string
+		value object.Object
+	}{
+		{"message", &object.String{Value: err.Message}},
+		{"stack", &object.Array{Elements: frames}},
+		{"value", value},
+	}
+	hash := object.NewHash(len(fields))
+	for _, field := range fields {
+		key := &object.String{Value: field.name}
+		hash.Set(key, field.value)
+	}
+	return e.allocate(hash)
+}
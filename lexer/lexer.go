@@ -2,8 +2,12 @@
 package lexer
 
 import (
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
+	"github.com/shanehowearth/interpreter/diagnostic"
 	"github.com/shanehowearth/interpreter/token"
 )
 
@@ -13,16 +17,51 @@ type Lexer struct {
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           rune // current char under examination
+
+	filename string
+	offset   int // byte offset of ch
+	line     int // line of ch
+	column   int // column of ch
+
+	keepComments bool
+	diagnostics  []diagnostic.Diagnostic
+
+	// interpolations holds, for each ${ } being lexed inside a string, the
+	// number of unclosed braces within it, so that the } ending it can be told
+	// apart from one closing a hash or block
+	interpolations []int
+}
+
+// Option - configures a Lexer
+type Option func(*Lexer)
+
+// WithFilename - the file name recorded in the position of every token
+func WithFilename(name string) Option {
+	return func(l *Lexer) {
+		l.filename = name
+	}
+}
+
+// WithComments - keep comments as trivia on the token that follows them,
+// rather than discarding them
+func WithComments() Option {
+	return func(l *Lexer) {
+		l.keepComments = true
+	}
 }
 
 // New -
-func New(input string) *Lexer {
-	l := &Lexer{input: []rune(input)}
+func New(input string, opts ...Option) *Lexer {
+	l := &Lexer{input: []rune(input), line: 1}
+	for _, opt := range opts {
+		opt(l)
+	}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	l.advancePosition()
 	if l.readPosition >= len(l.input) {
 		// Nothing read yet, or EOF
 		l.ch = 0
@@ -33,26 +72,72 @@ func (l *Lexer) readChar() {
 	l.readPosition++
 }
 
+// advancePosition moves the line, column, and offset past the current char
+func (l *Lexer) advancePosition() {
+	if l.readPosition == 0 {
+		// Nothing read yet, the first char is at column 1
+		l.column = 1
+		return
+	}
+	if l.position >= len(l.input) {
+		return
+	}
+	l.offset += utf8.RuneLen(l.ch)
+	if l.ch == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+}
+
+// pos - the position of the current char
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.offset,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
+// Diagnostics - the problems found in the input so far, each is also
+// returned as an ILLEGAL token
+func (l *Lexer) Diagnostics() []diagnostic.Diagnostic {
+	return l.diagnostics
+}
+
+func (l *Lexer) report(code diagnostic.Code, span token.Span, msg string) {
+	l.diagnostics = append(l.diagnostics, diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     code,
+		Span:     span,
+		Message:  msg,
+		Actual:   token.ILLEGAL,
+	})
+}
+
 // NextToken -
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
-	l.skipWhitespace()
+	comments, illegal := l.skipTrivia()
+	if illegal != nil {
+		return *illegal
+	}
+
+	start := l.pos()
 
 	switch l.ch {
 	case '!':
 		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			tok = token.Token{Type: token.NOT_EQ, Literal: string(ch) + string(l.ch)}
+			tok = l.newTwoCharToken(token.NOT_EQ)
 		} else {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '=':
 		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			tok = token.Token{Type: token.EQ, Literal: string(ch) + string(l.ch)}
+			tok = l.newTwoCharToken(token.EQ)
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -63,28 +148,90 @@ func (l *Lexer) NextToken() token.Token {
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1]++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
+		if n := len(l.interpolations); n > 0 {
+			if l.interpolations[n-1] == 0 {
+				l.interpolations = l.interpolations[:n-1]
+				return l.readString(start, comments, true)
+			}
+			l.interpolations[n-1]--
+		}
 		tok = newToken(token.RBRACE, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.PLUS_ASSIGN)
+		} else {
+			tok = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.MINUS_ASSIGN)
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.SLASH_ASSIGN)
+		} else {
+			tok = newToken(token.SLASH, l.ch)
+		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		switch l.peekChar() {
+		case '*':
+			tok = l.newTwoCharToken(token.POWER)
+		case '=':
+			tok = l.newTwoCharToken(token.ASTERISK_ASSIGN)
+		default:
+			tok = newToken(token.ASTERISK, l.ch)
+		}
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.newTwoCharToken(token.LT_EQ)
+		case '<':
+			tok = l.newTwoCharToken(token.LSHIFT)
+		default:
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.newTwoCharToken(token.GT_EQ)
+		case '>':
+			tok = l.newTwoCharToken(token.RSHIFT)
+		default:
+			tok = newToken(token.GT, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.newTwoCharToken(token.AND)
+		} else {
+			tok = newToken(token.AMPERSAND, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.newTwoCharToken(token.OR)
+		} else {
+			tok = newToken(token.PIPE, l.ch)
+		}
+	case '^':
+		tok = newToken(token.CARET, l.ch)
+	case '~':
+		tok = newToken(token.TILDE, l.ch)
 	case rune(0):
 		tok = newToken(token.EOF, l.ch)
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		return l.readString(start, comments, false)
+	case '`':
+		return l.readRawString(start, comments)
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
@@ -95,36 +242,206 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
-			return tok
+			return l.finish(tok, start, comments)
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
-			return tok
+			tok.Literal, tok.Type = l.readNumber()
+			return l.finish(tok, start, comments)
 		}
 		tok = newToken(token.ILLEGAL, l.ch)
 	}
 
 	l.readChar()
+	if tok.Type == token.ILLEGAL {
+		l.report(diagnostic.IllegalCharacter, token.Span{Start: start, End: l.pos()},
+			"illegal character "+strconv.QuoteRune([]rune(tok.Literal)[0]))
+	}
+	return l.finish(tok, start, comments)
+}
+
+// finish records the span of tok, which started at start, and attaches the
+// comments that preceded it
+func (l *Lexer) finish(tok token.Token, start token.Position, comments []token.Comment) token.Token {
+	tok.Span = token.Span{Start: start, End: l.pos()}
+	if l.keepComments {
+		tok.Comments = comments
+	}
 	return tok
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer, or a float when it has a fractional part or an
+// exponent, e.g. 3.14, 1e10, 2.5E-3
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+	tokenType := token.TokenType(token.INT)
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if (next == '+' || next == '-') && isDigit(l.peekCharAt(2)) || isDigit(next) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return string(l.input[position:l.position]), tokenType
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return string(l.input[position:l.position])
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
-	for {
+// readString reads a double quoted string, the Literal of the token is the
+// string with its escape sequences decoded. A string that is not terminated,
+// or has an invalid escape sequence, is returned as an ILLEGAL token.
+//
+// Reading stops early at a ${, the expression that follows is lexed as normal
+// tokens, and the } that ends it resumes the string, with resumed set.
+func (l *Lexer) readString(start token.Position, comments []token.Comment, resumed bool) token.Token {
+	position := l.position
+	var out strings.Builder
+	valid := true
+	interpolating := false
+
+	l.readChar()
+	for l.ch != '"' && !interpolating {
+		switch l.ch {
+		case 0:
+			return l.unterminated(start, position, comments, "string literal not terminated")
+		case '\\':
+			escStart, escPosition := l.pos(), l.position
+			r, ok := l.readEscape()
+			if ok {
+				out.WriteRune(r)
+			} else if l.ch != 0 {
+				valid = false
+				l.report(diagnostic.InvalidEscape, token.Span{Start: escStart, End: l.pos()},
+					"invalid escape sequence "+string(l.input[escPosition:l.position]))
+			}
+		case '$':
+			if l.peekChar() == '{' {
+				interpolating = true
+				l.interpolations = append(l.interpolations, 0)
+				// step over the $, the { is stepped over below
+				l.readChar()
+				break
+			}
+			out.WriteRune(l.ch)
+			l.readChar()
+		default:
+			out.WriteRune(l.ch)
+			l.readChar()
+		}
+	}
+	// step over the closing quote, or the { of ${
+	l.readChar()
+
+	if !valid {
+		tok := token.Token{Type: token.ILLEGAL, Literal: string(l.input[position:l.position])}
+		return l.finish(tok, start, comments)
+	}
+
+	var tokenType token.TokenType
+	switch {
+	case !resumed && !interpolating:
+		tokenType = token.STRING
+	case !resumed:
+		tokenType = token.INTERP_START
+	case interpolating:
+		tokenType = token.INTERP_MID
+	default:
+		tokenType = token.INTERP_END
+	}
+	return l.finish(token.Token{Type: tokenType, Literal: out.String()}, start, comments)
+}
+
+// readEscape reads the escape sequence starting at the current backslash,
+// leaving the lexer on the char after it
+func (l *Lexer) readEscape() (rune, bool) {
+	l.readChar()
+	ch := l.ch
+	if ch == 0 {
+		return 0, false
+	}
+	l.readChar()
+
+	switch ch {
+	case 'n':
+		return '\n', true
+	case 't':
+		return '\t', true
+	case 'r':
+		return '\r', true
+	case '"':
+		return '"', true
+	case '$':
+		return '$', true
+	case '\\':
+		return '\\', true
+	case 'u':
+		// \u{1F600}, one to six hex digits
+		if l.ch != '{' {
+			return 0, false
+		}
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		position := l.position
+		for isHexDigit(l.ch) {
+			l.readChar()
+		}
+		digits := string(l.input[position:l.position])
+		if l.ch != '}' || len(digits) == 0 || len(digits) > 6 {
+			return 0, false
+		}
+		l.readChar()
+		value, err := strconv.ParseUint(digits, 16, 32)
+		if err != nil || !utf8.ValidRune(rune(value)) {
+			return 0, false
 		}
+		return rune(value), true
+	default:
+		return 0, false
 	}
-	return string(l.input[position:l.position])
+}
+
+// readRawString reads a backtick quoted string, which has no escape sequences
+// and may span lines
+func (l *Lexer) readRawString(start token.Position, comments []token.Comment) token.Token {
+	position := l.position
+	l.readChar()
+	for l.ch != '`' {
+		if l.ch == 0 {
+			return l.unterminated(start, position, comments, "raw string literal not terminated")
+		}
+		l.readChar()
+	}
+	tok := token.Token{Type: token.STRING, Literal: string(l.input[position+1 : l.position])}
+	// step over the closing backtick
+	l.readChar()
+	return l.finish(tok, start, comments)
+}
+
+// unterminated reports a string starting at position that ran into the end of
+// the input, returning it as an ILLEGAL token
+func (l *Lexer) unterminated(start token.Position, position int, comments []token.Comment, msg string) token.Token {
+	span := token.Span{Start: start, End: l.pos()}
+	l.report(diagnostic.UnterminatedString, span, msg)
+	tok := token.Token{Type: token.ILLEGAL, Literal: string(l.input[position:l.position])}
+	return l.finish(tok, start, comments)
+}
+
+func isHexDigit(c rune) bool {
+	return isDigit(c) || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
 }
 
 func isDigit(c rune) bool {
@@ -136,10 +453,15 @@ func isLetter(c rune) bool {
 }
 
 func (l *Lexer) peekChar() (r rune) {
-	if l.readPosition >= len(l.input) {
+	return l.peekCharAt(1)
+}
+
+// peekCharAt looks n chars past the current one
+func (l *Lexer) peekCharAt(n int) (r rune) {
+	if l.position+n >= len(l.input) {
 		return r
 	}
-	return l.input[l.readPosition]
+	return l.input[l.position+n]
 }
 
 func (l *Lexer) readIdentifier() string {
@@ -156,6 +478,73 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
+// skipTrivia skips whitespace and comments, returning the comments. A block
+// comment that is never closed is returned as an ILLEGAL token instead.
+func (l *Lexer) skipTrivia() ([]token.Comment, *token.Token) {
+	var comments []token.Comment
+	for {
+		l.skipWhitespace()
+		if l.ch != '/' || (l.peekChar() != '/' && l.peekChar() != '*') {
+			return comments, nil
+		}
+
+		start := l.pos()
+		position := l.position
+		terminated := true
+		if l.peekChar() == '/' {
+			l.skipLineComment()
+		} else {
+			terminated = l.skipBlockComment()
+		}
+		comment := token.Comment{
+			Text: string(l.input[position:l.position]),
+			Span: token.Span{Start: start, End: l.pos()},
+		}
+
+		if !terminated {
+			l.report(diagnostic.UnterminatedComment, comment.Span, "block comment not terminated")
+			tok := token.Token{Type: token.ILLEGAL, Literal: comment.Text, Span: comment.Span}
+			if l.keepComments {
+				tok.Comments = comments
+			}
+			return nil, &tok
+		}
+		comments = append(comments, comment)
+	}
+}
+
+// skipLineComment skips a // comment, leaving the newline that ends it
+func (l *Lexer) skipLineComment() {
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+}
+
+// skipBlockComment skips a /* */ comment, reporting whether the closing */
+// was found before the end of the input
+func (l *Lexer) skipBlockComment() bool {
+	// step over the opening /*
+	l.readChar()
+	l.readChar()
+	for l.ch != 0 {
+		if l.ch == '*' && l.peekChar() == '/' {
+			l.readChar()
+			l.readChar()
+			return true
+		}
+		l.readChar()
+	}
+	return false
+}
+
+// newTwoCharToken makes a token of the current char and the next one,
+// leaving the lexer on the second
+func (l *Lexer) newTwoCharToken(tokenType token.TokenType) token.Token {
+	ch := l.ch
+	l.readChar()
+	return token.Token{Type: tokenType, Literal: string(ch) + string(l.ch)}
+}
+
 func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
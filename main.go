@@ -2,6 +2,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"os/user"
@@ -10,11 +11,23 @@ import (
 )
 
 func main() {
+	optimize := flag.Bool("optimize", false, "optimize each line before it is run")
+	dump := flag.Bool("dump", false, "print each line once it has been optimized, implies -optimize")
+	flag.Parse()
+
+	var opts []repl.Option
+	if *optimize {
+		opts = append(opts, repl.WithOptimizer())
+	}
+	if *dump {
+		opts = append(opts, repl.WithDump())
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
 	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(os.Stdin, os.Stdout, opts...)
 }
@@ -13,9 +13,16 @@ func NewEnvironment() *Environment {
 	return &Environment{store: s, outer: nil}
 }
 
+// NewFrame - an environment for a call, a loop or a catch block, with size
+// slots for the variables the resolver found in it
+func NewFrame(size int, outer *Environment) *Environment {
+	return &Environment{slots: make([]Object, size), outer: outer}
+}
+
 // Environment -
 type Environment struct {
 	store map[string]Object
+	slots []Object
 	outer *Environment
 }
 
@@ -28,8 +35,53 @@ func (e *Environment) Get(name string) (Object, bool) {
 	return obj, ok
 }
 
+// Assign - updates the nearest existing binding of name, reporting false when
+// there is none
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return val, true
+		}
+	}
+	return nil, false
+}
+
 // Set -
 func (e *Environment) Set(name string, val Object) Object {
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val
 }
+
+// Slot - the value in slot of the environment depth environments out, nil
+// when the variable has not been bound yet
+func (e *Environment) Slot(depth, slot int) Object {
+	for ; depth > 0; depth-- {
+		e = e.outer
+	}
+	return e.slots[slot]
+}
+
+// SetSlot - sets slot of the environment depth environments out
+func (e *Environment) SetSlot(depth, slot int, val Object) Object {
+	for ; depth > 0; depth-- {
+		e = e.outer
+	}
+	e.slots[slot] = val
+	return val
+}
+
+// Scope - the variables of a call or a block in the vm, held in slots that the
+// compiler assigns rather than by name
+type Scope struct {
+	Vars  []Object
+	Outer *Scope
+}
+
+// NewScope -
+func NewScope(size int, outer *Scope) *Scope {
+	return &Scope{Vars: make([]Object, size), Outer: outer}
+}
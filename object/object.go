@@ -5,9 +5,14 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"math/big"
+	"strconv"
 	"strings"
 
 	"github.com/shanehowearth/interpreter/ast"
+	"github.com/shanehowearth/interpreter/code"
+	"github.com/shanehowearth/interpreter/token"
 )
 
 // ObjectType -
@@ -18,11 +23,16 @@ type ObjectType string
 // nolint: revive
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
+	BIGINT_OBJ       = "BIGINT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
+	COMPILED_FN_OBJ  = "COMPILED_FUNCTION"
 	STRING_OBJ       = "STRING"
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
@@ -33,10 +43,18 @@ const (
 type Object interface {
 	Type() ObjectType
 	Inspect() string
+	// Equals reports whether other is the same value as a key of a hash,
+	// integers, big integers, floats, booleans, strings and null are compared
+	// by value and everything else by identity. It is for hash keys only, ==
+	// and != in a program compare numbers by value and everything else,
+	// strings included, by identity.
+	Equals(other Object) bool
 }
 
-// Hashable -
+// Hashable - an object that can be a key of a hash, objects that are Equals
+// have the same HashKey but different ones may have it too
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
 
@@ -46,21 +64,78 @@ type HashPair struct {
 	Value Object
 }
 
-// Hash -
+// Hash - the pairs of a hash are kept in the order their keys were first
+// added. A key is looked up by its HashKey, and as different keys can have
+// the same HashKey it is then compared with the keys found by Equals.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	Pairs   []HashPair        // in the order their keys were first added
+	buckets map[HashKey][]int // the indexes in Pairs of the keys with each HashKey
+}
+
+// NewHash - an empty hash with room for size pairs
+func NewHash(size int) *Hash {
+	return &Hash{
+		Pairs:   make([]HashPair, 0, size),
+		buckets: make(map[HashKey][]int, size),
+	}
+}
+
+// find is the index in Pairs of the pair of key, -1 if there is none, and
+// the HashKey of key
+func (h *Hash) find(key Hashable) (int, HashKey) {
+	hashed := key.HashKey()
+	for _, idx := range h.buckets[hashed] {
+		if key.Equals(h.Pairs[idx].Key) {
+			return idx, hashed
+		}
+	}
+	return -1, hashed
+}
+
+// Get - the pair of key
+func (h *Hash) Get(key Hashable) (HashPair, bool) {
+	idx, _ := h.find(key)
+	if idx < 0 {
+		return HashPair{}, false
+	}
+	return h.Pairs[idx], true
+}
+
+// Set - sets the value of key, a new key goes after the others and an
+// existing key keeps its place
+func (h *Hash) Set(key Hashable, value Object) {
+	idx, hashed := h.find(key)
+	if idx >= 0 {
+		h.Pairs[idx].Value = value
+		return
+	}
+	if h.buckets == nil {
+		h.buckets = make(map[HashKey][]int)
+	}
+	h.buckets[hashed] = append(h.buckets[hashed], len(h.Pairs))
+	h.Pairs = append(h.Pairs, HashPair{Key: key, Value: value})
 }
 
 // Type -
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
 
+// Equals -
+func (h *Hash) Equals(other Object) bool {
+	o, ok := other.(*Hash)
+	return ok && o == h
+}
+
 // Inspect -
 func (h *Hash) Inspect() string {
+	return inspect(h, make(map[Object]bool))
+}
+
+func (h *Hash) inspect(visiting map[Object]bool) string {
 	var out bytes.Buffer
 	pairs := []string{}
 	for _, pair := range h.Pairs {
 		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(), pair.Value.Inspect()))
+			inspect(pair.Key, visiting), inspect(pair.Value, visiting)))
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
@@ -87,12 +162,83 @@ func (i *Integer) Type() ObjectType {
 	return INTEGER_OBJ
 }
 
+// Equals -
+func (i *Integer) Equals(other Object) bool {
+	o, ok := other.(*Integer)
+	return ok && o.Value == i.Value
+}
+
 // HashKey -
 func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 
 }
 
+// BigInt - an integer outside the range of an Integer, the evaluator turns
+// results that fit in an int64 back into an Integer
+type BigInt struct {
+	Value *big.Int
+}
+
+// Inspect -
+func (bi *BigInt) Inspect() string { return bi.Value.String() }
+
+// Type -
+func (bi *BigInt) Type() ObjectType { return BIGINT_OBJ }
+
+// Equals -
+func (bi *BigInt) Equals(other Object) bool {
+	o, ok := other.(*BigInt)
+	return ok && o.Value.Cmp(bi.Value) == 0
+}
+
+// HashKey -
+func (bi *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write(bi.Value.Bytes())
+	value := h.Sum64()
+	if bi.Value.Sign() < 0 {
+		value = ^value
+	}
+	return HashKey{Type: bi.Type(), Value: value}
+}
+
+// Float -
+type Float struct {
+	Value float64
+}
+
+// Inspect - always shows a decimal point or exponent so that a whole
+// float is not mistaken for an Integer, e.g. 2.0 rather than 2
+func (f *Float) Inspect() string {
+	if math.IsInf(f.Value, 0) || math.IsNaN(f.Value) {
+		return strconv.FormatFloat(f.Value, 'g', -1, 64)
+	}
+	if abs := math.Abs(f.Value); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		return strconv.FormatFloat(f.Value, 'g', -1, 64)
+	}
+	s := strconv.FormatFloat(f.Value, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// Type -
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Equals - floats are compared bit for bit, as their HashKeys are, so NaN
+// equals itself and 0.0 does not equal -0.0
+func (f *Float) Equals(other Object) bool {
+	o, ok := other.(*Float)
+	return ok && math.Float64bits(o.Value) == math.Float64bits(f.Value)
+}
+
+// HashKey -
+func (f *Float) HashKey() HashKey {
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 // Boolean -
 type Boolean struct {
 	Value bool
@@ -104,6 +250,12 @@ func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
 // Inspect -
 func (b *Boolean) Inspect() string { return fmt.Sprintf("%t", b.Value) }
 
+// Equals -
+func (b *Boolean) Equals(other Object) bool {
+	o, ok := other.(*Boolean)
+	return ok && o.Value == b.Value
+}
+
 // HashKey -
 func (b *Boolean) HashKey() HashKey {
 	var value uint64
@@ -127,6 +279,12 @@ func (n *Null) Type() ObjectType { return NULL_OBJ }
 // Inspect -
 func (n *Null) Inspect() string { return "null" }
 
+// Equals -
+func (n *Null) Equals(other Object) bool {
+	_, ok := other.(*Null)
+	return ok
+}
+
 // ReturnValue -
 type ReturnValue struct {
 	Value Object
@@ -138,22 +296,128 @@ func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 // Inspect -
 func (rv *ReturnValue) Inspect() string { return rv.Value.Inspect() }
 
+// Equals -
+func (rv *ReturnValue) Equals(other Object) bool {
+	o, ok := other.(*ReturnValue)
+	return ok && o == rv
+}
+
+// Break - the signal a break statement sends to the enclosing loop
+type Break struct{}
+
+// Type -
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+
+// Inspect -
+func (b *Break) Inspect() string { return "break" }
+
+// Equals -
+func (b *Break) Equals(other Object) bool {
+	o, ok := other.(*Break)
+	return ok && o == b
+}
+
+// Continue - the signal a continue statement sends to the enclosing loop
+type Continue struct{}
+
+// Type -
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+
+// Inspect -
+func (c *Continue) Inspect() string { return "continue" }
+
+// Equals -
+func (c *Continue) Equals(other Object) bool {
+	o, ok := other.(*Continue)
+	return ok && o == c
+}
+
+// Frame - a call on the call stack
+type Frame struct {
+	Function string         // the name of the function called, empty if it has none
+	Pos      token.Position // where the call was made
+}
+
+// String -
+func (f Frame) String() string {
+	name := f.Function
+	if name == "" {
+		name = "<anonymous>"
+	}
+	return name + " at " + f.Pos.String()
+}
+
 // Error -
 type Error struct {
 	Message string
+	Pos     token.Position // where in the source the error was raised, if known
+	Stack   []Frame        // the calls being made when the error was raised, outermost first
+	Cause   error          // why evaluation was stopped, when it was stopped from outside the script
+	Value   Object         // the value given to throw, nil when the error was raised by the interpreter
 }
 
 // Type -
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 
 // Inspect -
-func (e *Error) Inspect() string { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "ERROR: " + e.Pos.String() + ": " + e.Message
+	}
+	return "ERROR: " + e.Message
+}
+
+// Equals -
+func (e *Error) Equals(other Object) bool {
+	o, ok := other.(*Error)
+	return ok && o == e
+}
+
+// maxRepeatedFrames is the number of times a frame is shown in a row in a
+// traceback, the rest of the repeats are summarised
+const maxRepeatedFrames = 3
+
+// Traceback - the error with the calls that led to it, in the style of
+// Python, e.g.
+//
+//	Traceback (most recent call last):
+//	  main at 5:5
+//	  helper at 2:13
+//	ERROR: 1:22: type mismatch: INTEGER + BOOLEAN
+//
+// Runs of the same frame, as in deep recursion, are shortened.
+func (e *Error) Traceback() string {
+	if len(e.Stack) == 0 {
+		return e.Inspect()
+	}
+
+	var out bytes.Buffer
+	out.WriteString("Traceback (most recent call last):\n")
+	for idx := 0; idx < len(e.Stack); {
+		frame := e.Stack[idx]
+		repeats := 1
+		for idx+repeats < len(e.Stack) && e.Stack[idx+repeats] == frame {
+			repeats++
+		}
+		for i := 0; i < repeats && i < maxRepeatedFrames; i++ {
+			out.WriteString("  " + frame.String() + "\n")
+		}
+		if repeats > maxRepeatedFrames {
+			fmt.Fprintf(&out, "  [previous frame repeated %d more times]\n", repeats-maxRepeatedFrames)
+		}
+		idx += repeats
+	}
+	out.WriteString(e.Inspect())
+	return out.String()
+}
 
 // Function -
 type Function struct {
+	Name       string // the name given to the function by let, if any
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Slots      int // the size of the frame of a call
 }
 
 // Type -
@@ -176,6 +440,60 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+// Equals -
+func (f *Function) Equals(other Object) bool {
+	o, ok := other.(*Function)
+	return ok && o == f
+}
+
+// CompiledFunction - a function literal compiled to bytecode
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int // the slots in the scope of a call, the parameters first
+	NumParameters int
+	Name          string // the name given to the function by let, if any
+
+	// the source positions of the instructions that can raise an error, and
+	// the identifiers of those that use a variable, by offset
+	Positions map[int]token.Position
+	Names     map[int]string
+}
+
+// Type -
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FN_OBJ }
+
+// Inspect -
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Equals -
+func (cf *CompiledFunction) Equals(other Object) bool {
+	o, ok := other.(*CompiledFunction)
+	return ok && o == cf
+}
+
+// Closure - a compiled function with the scope it was created in, to a
+// program it is a function like any other
+type Closure struct {
+	Fn    *CompiledFunction
+	Scope *Scope
+}
+
+// Type -
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+
+// Inspect -
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+// Equals -
+func (c *Closure) Equals(other Object) bool {
+	o, ok := other.(*Closure)
+	return ok && o == c
+}
+
 // String -
 type String struct {
 	Value string
@@ -187,6 +505,12 @@ func (s *String) Type() ObjectType { return STRING_OBJ }
 // Inspect -
 func (s *String) Inspect() string { return s.Value }
 
+// Equals -
+func (s *String) Equals(other Object) bool {
+	o, ok := other.(*String)
+	return ok && o.Value == s.Value
+}
+
 // HashKey -
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
@@ -209,6 +533,12 @@ func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 // Inspect -
 func (b *Builtin) Inspect() string { return "builtin function" }
 
+// Equals -
+func (b *Builtin) Equals(other Object) bool {
+	o, ok := other.(*Builtin)
+	return ok && o == b
+}
+
 // Array -
 type Array struct {
 	Elements []Object
@@ -219,11 +549,15 @@ func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
 
 // Inspect -
 func (ao *Array) Inspect() string {
+	return inspect(ao, make(map[Object]bool))
+}
+
+func (ao *Array) inspect(visiting map[Object]bool) string {
 	var out bytes.Buffer
 
 	elements := []string{}
 	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
+		elements = append(elements, inspect(e, visiting))
 	}
 
 	out.WriteString("[")
@@ -232,3 +566,32 @@ func (ao *Array) Inspect() string {
 
 	return out.String()
 }
+
+// Equals -
+func (ao *Array) Equals(other Object) bool {
+	o, ok := other.(*Array)
+	return ok && o == ao
+}
+
+// inspect is the Inspect of obj inside the arrays and hashes in visiting. As
+// they can be changed in place an array or hash can contain itself, where it
+// repeats it is shown as [...] or {...}.
+func inspect(obj Object, visiting map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *Array:
+		if visiting[obj] {
+			return "[...]"
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+		return obj.inspect(visiting)
+	case *Hash:
+		if visiting[obj] {
+			return "{...}"
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+		return obj.inspect(visiting)
+	}
+	return obj.Inspect()
+}
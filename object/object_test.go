@@ -1,6 +1,12 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/shanehowearth/interpreter/token"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -17,3 +23,173 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+// collidingKey is a string whose HashKey is the same whatever its value
+type collidingKey struct {
+	String
+}
+
+func (k *collidingKey) HashKey() HashKey { return HashKey{Type: STRING_OBJ, Value: 1} }
+
+func (k *collidingKey) Equals(other Object) bool {
+	o, ok := other.(*collidingKey)
+	return ok && o.Value == k.Value
+}
+
+func TestHashCollisions(t *testing.T) {
+	a := &collidingKey{String{Value: "a"}}
+	b := &collidingKey{String{Value: "b"}}
+	hash := NewHash(0)
+	hash.Set(a, &Integer{Value: 1})
+	hash.Set(b, &Integer{Value: 2})
+	hash.Set(&collidingKey{String{Value: "a"}}, &Integer{Value: 3})
+
+	if len(hash.Pairs) != 2 {
+		t.Fatalf("hash has wrong num of pairs. got=%d", len(hash.Pairs))
+	}
+	tests := []struct {
+		key      Hashable
+		expected int64
+	}{
+		{a, 3},
+		{b, 2},
+	}
+	for _, tt := range tests {
+		pair, ok := hash.Get(tt.key)
+		if !ok {
+			t.Errorf("no pair for key %s", tt.key.Inspect())
+			continue
+		}
+		if value := pair.Value.(*Integer).Value; value != tt.expected {
+			t.Errorf("wrong value for key %s. expected=%d, got=%d", tt.key.Inspect(), tt.expected, value)
+		}
+	}
+	if _, ok := hash.Get(&collidingKey{String{Value: "c"}}); ok {
+		t.Errorf("found a pair for a key that was never set")
+	}
+	if hash.Inspect() != "{a: 3, b: 2}" {
+		t.Errorf("wrong order of pairs. got=%q", hash.Inspect())
+	}
+
+	// a hash made without NewHash can be set
+	empty := &Hash{}
+	empty.Set(&String{Value: "x"}, &Integer{Value: 1})
+	if _, ok := empty.Get(&String{Value: "x"}); !ok {
+		t.Errorf("no pair for key x in a zero hash")
+	}
+}
+
+func TestEquals(t *testing.T) {
+	array := &Array{}
+	tests := []struct {
+		left, right Object
+		expected    bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &Integer{Value: 2}, false},
+		{&Integer{Value: 1}, &Float{Value: 1}, false},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{&String{Value: "a"}, &String{Value: "b"}, false},
+		{&Boolean{Value: true}, &Boolean{Value: true}, true},
+		{&BigInt{Value: new(big.Int).Lsh(big.NewInt(1), 64)}, &BigInt{Value: new(big.Int).Lsh(big.NewInt(1), 64)}, true},
+		{&Float{Value: math.NaN()}, &Float{Value: math.NaN()}, true},
+		{&Float{Value: 0}, &Float{Value: math.Copysign(0, -1)}, false},
+		{&Null{}, &Null{}, true},
+		{array, array, true},
+		{array, &Array{}, false},
+	}
+	for _, tt := range tests {
+		if tt.left.Equals(tt.right) != tt.expected {
+			t.Errorf("%s equals %s wrong. expected=%t", tt.left.Inspect(), tt.right.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{2, "2.0"},
+		{3.14, "3.14"},
+		{-0.5, "-0.5"},
+		{1000000, "1000000.0"},
+		{1e21, "1e+21"},
+		{1.5e-7, "1.5e-07"},
+	}
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("Inspect() of %g wrong. expected=%q, got=%q", tt.value, tt.expected, f.Inspect())
+		}
+	}
+}
+
+func TestInspectCycles(t *testing.T) {
+	array := &Array{Elements: []Object{&Integer{Value: 1}}}
+	array.Elements = append(array.Elements, array)
+
+	key := &String{Value: "self"}
+	hash := NewHash(1)
+	hash.Set(key, hash)
+
+	shared := &Array{Elements: []Object{&Integer{Value: 2}}}
+
+	tests := []struct {
+		value    Object
+		expected string
+	}{
+		{array, "[1, [...]]"},
+		{hash, "{self: {...}}"},
+		{&Array{Elements: []Object{hash, array}}, "[{self: {...}}, [1, [...]]]"},
+		// an array seen twice but not inside itself is shown in full
+		{&Array{Elements: []Object{shared, shared}}, "[[2], [2]]"},
+	}
+	for _, tt := range tests {
+		if tt.value.Inspect() != tt.expected {
+			t.Errorf("Inspect() wrong. expected=%q, got=%q", tt.expected, tt.value.Inspect())
+		}
+	}
+}
+
+func TestBigIntHashKey(t *testing.T) {
+	big1 := &BigInt{Value: new(big.Int).Lsh(big.NewInt(1), 64)}
+	big2 := &BigInt{Value: new(big.Int).Lsh(big.NewInt(1), 64)}
+	negative := &BigInt{Value: new(big.Int).Neg(big1.Value)}
+
+	if big1.HashKey() != big2.HashKey() {
+		t.Errorf("big integers with same value have different hash keys")
+	}
+	if big1.HashKey() == negative.HashKey() {
+		t.Errorf("big integers with different signs have same hash keys")
+	}
+}
+
+func TestErrorTraceback(t *testing.T) {
+	pos := func(line, column int) token.Position {
+		return token.Position{Line: line, Column: column}
+	}
+
+	err := &Error{Message: "boom", Pos: pos(1, 3)}
+	if err.Traceback() != "ERROR: 1:3: boom" {
+		t.Errorf("error without a stack has wrong traceback. got=%q", err.Traceback())
+	}
+
+	err.Stack = []Frame{{Function: "main", Pos: pos(9, 1)}}
+	for i := 0; i < 5; i++ {
+		err.Stack = append(err.Stack, Frame{Function: "f", Pos: pos(2, 4)})
+	}
+	err.Stack = append(err.Stack, Frame{Pos: pos(3, 7)})
+
+	expected := `Traceback (most recent call last):
+  main at 9:1
+  f at 2:4
+  f at 2:4
+  f at 2:4
+  [previous frame repeated 2 more times]
+  <anonymous> at 3:7
+ERROR: 1:3: boom`
+	if err.Traceback() != expected {
+		t.Errorf("wrong traceback. expected=\n%s\ngot=\n%s", expected, err.Traceback())
+	}
+}
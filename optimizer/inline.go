@@ -0,0 +1,57 @@
+package optimizer
+
+import "github.com/shanehowearth/interpreter/ast"
+
+// inlinable finds the names that are declared once in program, by a let
+// statement, and never assigned to. Wherever such a name refers to a variable
+// it is that one, and it keeps the value it was bound to.
+func inlinable(program *ast.Program) map[string]bool {
+	lets := make(map[string]int)
+	others := make(map[string]bool)
+	var visit func(node ast.Node)
+	visit = func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			lets[node.Name.Value]++
+		case *ast.AssignExpression:
+			if ident, ok := node.Target.(*ast.Identifier); ok {
+				others[ident.Value] = true
+			}
+		case *ast.FunctionLiteral:
+			for _, param := range node.Parameters {
+				others[param.Value] = true
+			}
+		case *ast.ForInStatement:
+			others[node.Variable.Value] = true
+		case *ast.TryExpression:
+			if node.CatchParam != nil {
+				others[node.CatchParam.Value] = true
+			}
+		}
+		ast.Children(node, visit)
+	}
+	visit(program)
+
+	names := make(map[string]bool)
+	for name, count := range lets {
+		if count == 1 && !others[name] {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// hasCall reports whether there is a call anywhere in node
+func hasCall(node ast.Node) bool {
+	found := false
+	var visit func(node ast.Node)
+	visit = func(node ast.Node) {
+		if _, ok := node.(*ast.CallExpression); ok {
+			found = true
+			return
+		}
+		ast.Children(node, visit)
+	}
+	visit(node)
+	return found
+}
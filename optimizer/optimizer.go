@@ -0,0 +1,363 @@
+// Package optimizer - rewrites a program into a simpler one with the same
+// meaning
+package optimizer
+
+import (
+	"strconv"
+
+	"github.com/shanehowearth/interpreter/ast"
+	"github.com/shanehowearth/interpreter/diagnostic"
+	"github.com/shanehowearth/interpreter/evaluator"
+	"github.com/shanehowearth/interpreter/object"
+	"github.com/shanehowearth/interpreter/resolver"
+	"github.com/shanehowearth/interpreter/token"
+)
+
+type optimizer struct {
+	// operators are applied to constants as the evaluator applies them, an
+	// operation that fails or does not give an integer, a string or a
+	// boolean is left to be done when the program is run
+	rt *evaluator.Evaluator
+
+	inlinable map[string]bool              // names bound once by let and never assigned
+	constants map[string]*ast.LetStatement // those bound to constants so far
+	functions int                          // the number of functions the optimizer is in
+}
+
+// Optimize - rewrites program in place and returns it. Integer, string and
+// boolean expressions with constant operands are folded, if expressions with
+// a constant condition are replaced by the branch that is taken, and the uses
+// of variables bound by let to an integer or boolean constant, and never
+// assigned to, are replaced by the constant. A global is only replaced until
+// the program makes a call, which can run a function of an earlier program
+// that assigns to it. Strings made by folding are literals, which do not
+// count towards a memory limit. A program the resolver reports an error in is
+// returned unchanged.
+func Optimize(program *ast.Program) *ast.Program {
+	for _, d := range resolver.Resolve(program) {
+		if d.Severity == diagnostic.Error {
+			return program
+		}
+	}
+
+	o := &optimizer{
+		rt:        evaluator.New(),
+		inlinable: inlinable(program),
+		constants: make(map[string]*ast.LetStatement),
+	}
+	program.Statements = o.scope(program.Statements)
+	return program
+}
+
+// scope optimizes the statements that start a scope. A constant bound by a
+// let statement among them is inlined in the statements after it, where the
+// let statement is known to have been run, and nowhere else.
+func (o *optimizer) scope(stmts []ast.Statement) []ast.Statement {
+	var bound []string
+	stmts = o.statements(stmts, &bound)
+	o.unbind(bound)
+	return stmts
+}
+
+// bind records the constant let binds, if it can be inlined, adding its name
+// to bound
+func (o *optimizer) bind(let *ast.LetStatement, bound *[]string) {
+	if !o.inlinable[let.Name.Value] {
+		return
+	}
+	switch let.Value.(type) {
+	case *ast.IntegerLiteral, *ast.Boolean:
+		o.constants[let.Name.Value] = let
+		*bound = append(*bound, let.Name.Value)
+	}
+}
+
+func (o *optimizer) unbind(bound []string) {
+	for _, name := range bound {
+		delete(o.constants, name)
+	}
+}
+
+// called drops the global constants once a call is made at the top of the
+// program, the function called can be one from an earlier program that
+// assigns to them. A call in a function is made when the function is.
+func (o *optimizer) called() {
+	if o.functions > 0 {
+		return
+	}
+	for name, let := range o.constants {
+		if !let.Name.Local {
+			delete(o.constants, name)
+		}
+	}
+}
+
+// loop drops the global constants before a loop that makes a call, the uses
+// in the loop can follow the call of an earlier iteration
+func (o *optimizer) loop(node ast.Node) {
+	if hasCall(node) {
+		o.called()
+	}
+}
+
+// statements optimizes a list of statements, binding the constants of the
+// let statements in the list itself when bound is not nil. An if statement
+// with a constant condition is replaced by the statements of the branch
+// taken, unless the value of the list would change.
+func (o *optimizer) statements(stmts []ast.Statement, bound *[]string) []ast.Statement {
+	var out []ast.Statement
+	for i, stmt := range stmts {
+		stmt = o.statement(stmt)
+
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			if bound != nil {
+				o.bind(stmt, bound)
+			}
+		case *ast.ExpressionStatement:
+			if ie, ok := stmt.Expression.(*ast.IfExpression); ok {
+				if branch, ok := o.branch(ie); ok {
+					// the value of the last statement is that of the list, an
+					// empty branch has none of its own
+					last := i == len(stmts)-1
+					if !last || branch != nil && len(branch.Statements) > 0 {
+						if branch != nil {
+							out = append(out, branch.Statements...)
+						}
+						continue
+					}
+				}
+			}
+		}
+		out = append(out, stmt)
+	}
+	return out
+}
+
+// branch is the branch of ie that is taken when its condition is constant,
+// nil when it is false and there is no else branch
+func (o *optimizer) branch(ie *ast.IfExpression) (*ast.BlockStatement, bool) {
+	condition, ok := constant(ie.Condition)
+	if !ok {
+		return nil, false
+	}
+	if evaluator.IsTruthy(condition) {
+		return ie.Consequence, true
+	}
+	return ie.Alternative, true
+}
+
+func (o *optimizer) statement(stmt ast.Statement) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		stmt.Value = o.expression(stmt.Value)
+	case *ast.ExpressionStatement:
+		stmt.Expression = o.expression(stmt.Expression)
+	case *ast.ReturnStatement:
+		stmt.ReturnValue = o.expression(stmt.ReturnValue)
+	case *ast.ThrowStatement:
+		stmt.Value = o.expression(stmt.Value)
+	case *ast.WhileStatement:
+		// a call in a loop is made before the next iteration
+		o.loop(stmt)
+		stmt.Condition = o.expression(stmt.Condition)
+		o.block(stmt.Body)
+	case *ast.ForStatement:
+		o.loop(stmt)
+		o.forStatement(stmt)
+	case *ast.ForInStatement:
+		stmt.Iterable = o.expression(stmt.Iterable)
+		o.loop(stmt)
+		stmt.Body.Statements = o.scope(stmt.Body.Statements)
+	}
+	return stmt
+}
+
+// forStatement optimizes a C style for loop, the constants bound by its init
+// are inlined in the rest of the loop
+func (o *optimizer) forStatement(node *ast.ForStatement) {
+	var bound []string
+	if node.Init != nil {
+		node.Init = o.statement(node.Init)
+		if let, ok := node.Init.(*ast.LetStatement); ok {
+			o.bind(let, &bound)
+		}
+	}
+	if node.Condition != nil {
+		node.Condition = o.expression(node.Condition)
+	}
+	node.Body.Statements = o.scope(node.Body.Statements)
+	if node.Update != nil {
+		node.Update = o.expression(node.Update)
+	}
+	o.unbind(bound)
+}
+
+// block optimizes a block that shares the scope it is in
+func (o *optimizer) block(block *ast.BlockStatement) {
+	if block != nil {
+		block.Statements = o.statements(block.Statements, nil)
+	}
+}
+
+func (o *optimizer) expression(expr ast.Expression) ast.Expression {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		// a global is left alone in a function, which can be called once
+		// another program has bound it again
+		if let, ok := o.constants[expr.Value]; ok && (let.Name.Local || o.functions == 0) {
+			value, _ := constant(let.Value)
+			return literal(value, expr.Token)
+		}
+	case *ast.PrefixExpression:
+		expr.Right = o.expression(expr.Right)
+		if right, ok := constant(expr.Right); ok {
+			if folded, ok := o.fold(o.rt.Prefix(expr.Operator, right), expr.Token); ok {
+				return folded
+			}
+		}
+	case *ast.InfixExpression:
+		return o.infix(expr)
+	case *ast.AssignExpression:
+		if target, ok := expr.Target.(*ast.IndexExpression); ok {
+			expr.Target = o.expression(target)
+		}
+		expr.Value = o.expression(expr.Value)
+	case *ast.IfExpression:
+		return o.ifExpression(expr)
+	case *ast.TryExpression:
+		o.block(expr.Block)
+		if expr.Catch != nil {
+			expr.Catch.Statements = o.scope(expr.Catch.Statements)
+		}
+		o.block(expr.Finally)
+	case *ast.FunctionLiteral:
+		o.functions++
+		expr.Body.Statements = o.scope(expr.Body.Statements)
+		o.functions--
+	case *ast.CallExpression:
+		expr.Function = o.expression(expr.Function)
+		for i, arg := range expr.Arguments {
+			expr.Arguments[i] = o.expression(arg)
+		}
+		o.called()
+	case *ast.IndexExpression:
+		expr.Left = o.expression(expr.Left)
+		expr.Index = o.expression(expr.Index)
+	case *ast.ArrayLiteral:
+		for i, element := range expr.Elements {
+			expr.Elements[i] = o.expression(element)
+		}
+	case *ast.HashLiteral:
+		for i, pair := range expr.Pairs {
+			expr.Pairs[i] = ast.HashPair{Key: o.expression(pair.Key), Value: o.expression(pair.Value)}
+		}
+	case *ast.InterpolatedString:
+		for i, part := range expr.Parts {
+			expr.Parts[i] = o.expression(part)
+		}
+	}
+	return expr
+}
+
+// infix folds an infix expression. The right operand of && and || is dropped
+// when the left one decides the result, as it is not evaluated then.
+func (o *optimizer) infix(expr *ast.InfixExpression) ast.Expression {
+	expr.Left = o.expression(expr.Left)
+	expr.Right = o.expression(expr.Right)
+
+	left, ok := constant(expr.Left)
+	if !ok {
+		return expr
+	}
+	if expr.Operator == "&&" || expr.Operator == "||" {
+		decided := evaluator.IsTruthy(left) == (expr.Operator == "||")
+		if decided {
+			return literal(nativeBool(expr.Operator == "||"), expr.Token)
+		}
+		if right, ok := constant(expr.Right); ok {
+			return literal(nativeBool(evaluator.IsTruthy(right)), expr.Token)
+		}
+		return expr
+	}
+
+	right, ok := constant(expr.Right)
+	if !ok {
+		return expr
+	}
+	if folded, ok := o.fold(o.rt.Infix(expr.Operator, left, right), expr.Token); ok {
+		return folded
+	}
+	return expr
+}
+
+// ifExpression replaces an if expression with a constant condition by the
+// expression of the branch taken when that is all there is in it, and drops
+// the other branch otherwise
+func (o *optimizer) ifExpression(expr *ast.IfExpression) ast.Expression {
+	expr.Condition = o.expression(expr.Condition)
+	o.block(expr.Consequence)
+	o.block(expr.Alternative)
+
+	branch, ok := o.branch(expr)
+	if !ok || branch == nil {
+		// without an else branch a false condition gives null
+		return expr
+	}
+	if len(branch.Statements) == 1 {
+		if stmt, ok := branch.Statements[0].(*ast.ExpressionStatement); ok {
+			return stmt.Expression
+		}
+	}
+	expr.Condition = literal(evaluator.TRUE, expr.Token)
+	expr.Consequence = branch
+	expr.Alternative = nil
+	return expr
+}
+
+// fold turns the result of an operation on constants into a literal
+func (o *optimizer) fold(result object.Object, tok token.Token) (ast.Expression, bool) {
+	switch result.(type) {
+	case *object.Integer, *object.String, *object.Boolean:
+		return literal(result, tok), true
+	}
+	return nil, false
+}
+
+// constant is the value of expr when it is an integer, string or boolean
+// literal
+func constant(expr ast.Expression) (object.Object, bool) {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: expr.Value}, true
+	case *ast.StringLiteral:
+		return &object.String{Value: expr.Value}, true
+	case *ast.Boolean:
+		return nativeBool(expr.Value), true
+	}
+	return nil, false
+}
+
+// literal is the literal for obj, at the position of tok
+func literal(obj object.Object, tok token.Token) ast.Expression {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		lit := strconv.FormatInt(obj.Value, 10)
+		return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: lit, Span: tok.Span}, Value: obj.Value}
+	case *object.String:
+		return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: obj.Value, Span: tok.Span}, Value: obj.Value}
+	case *object.Boolean:
+		if obj.Value {
+			return &ast.Boolean{Token: token.Token{Type: token.TRUE, Literal: "true", Span: tok.Span}, Value: true}
+		}
+		return &ast.Boolean{Token: token.Token{Type: token.FALSE, Literal: "false", Span: tok.Span}, Value: false}
+	}
+	return nil
+}
+
+func nativeBool(value bool) *object.Boolean {
+	if value {
+		return evaluator.TRUE
+	}
+	return evaluator.FALSE
+}
@@ -0,0 +1,98 @@
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/shanehowearth/interpreter/ast"
+	"github.com/shanehowearth/interpreter/evaluator"
+	"github.com/shanehowearth/interpreter/lexer"
+	"github.com/shanehowearth/interpreter/object"
+	"github.com/shanehowearth/interpreter/parser"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// folding
+		{"60 * 60 * 24", "86400"},
+		{"-(2 + 3)", "-5"},
+		{`"a" + "b"`, "ab"},
+		{"!true == false", "true"},
+		{"1 < 2 && 3 > 4", "false"},
+		{"false && x", "false"},
+		{"true || x", "true"},
+		{"true && x", "(true && x)"},
+		{"1.5 + 1", "(1.5 + 1)"},
+		// left for the program to do when it is run
+		{"9223372036854775807 + 1", "(9223372036854775807 + 1)"},
+		{"1 / 0", "(1 / 0)"},
+		{"1 + true", "(1 + true)"},
+
+		// dead branches
+		{"if (true) { 1 } else { 2 }", "1"},
+		{"if (60 > 61) { 1 } else { 2 }", "2"},
+		{"if (false) { a }; b", "b"},
+		{"if (true) { a; b }; c", "abc"},
+		{"let x = if (false) { 1 } else { a; b }", "let x = iftrue ab;"},
+		// the value of a program ending in if (false) { } is null
+		{"if (false) { a }", "iffalse a"},
+
+		// inlining
+		{"let secs = 60 * 60; let day = secs * 24; day", "let secs = 3600;let day = 86400;86400"},
+		{"let f = fn() { let k = 3; k * 2 }; f()", "let f = () let k = 3;6;f()"},
+		{"let debug = false; if (debug) { puts(1) }; 2", "let debug = false;2"},
+		// assigned, declared twice or shadowed
+		{"let n = 1; n = 2; n", "let n = 1;(n = 2)n"},
+		{"let n = 1; let n = 2; n", "let n = 1;let n = 2;n"},
+		{"let x = 1; let f = fn(x) { x }; x", "let x = 1;let f = (x) x;x"},
+		// a global in a function may be bound again by a later program
+		{"let g = 10; let f = fn() { g }; g + 1", "let g = 10;let f = () g;11"},
+		// the let in an if block may not have been run
+		{"let f = fn(c) { if (c) { let k = 1 }; k }", "let f = (c) ifc let k = 1;k;"},
+		// a call can run a function of an earlier program that assigns to a
+		// global, it is not inlined after one
+		{"let n = 1; bump(); n", "let n = 1;bump()n"},
+		{"let n = 1; f(n + 1); n", "let n = 1;f(2)n"},
+		{"let n = 1; let i = n; while (i < 3) { i += n; f() }", "let n = 1;let i = 1;while(i < 3) (i += n)f()"},
+		{"let n = 1; let f = fn() { n }; f(); let m = 2; m", "let n = 1;let f = () n;f()let m = 2;2"},
+		{"let f = fn() { let k = 3; g(); k }", "let f = () let k = 3;g()3;"},
+		// strings are compared by identity
+		{`let s = "a"; s == s`, "let s = a;(s == s)"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("%q: parser errors: %v", tt.input, p.Errors())
+		}
+		if got := Optimize(program).String(); got != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestOptimizeKeepsPositions(t *testing.T) {
+	program := parser.New(lexer.New("let x = 1;\nlet y = x + 2;")).ParseProgram()
+	Optimize(program)
+	value := program.Statements[1].(*ast.LetStatement).Value
+	if value.String() != "3" || value.Pos().String() != "2:11" {
+		t.Errorf("expected 3 at 2:11. got=%s at %s", value, value.Pos())
+	}
+}
+
+func TestOptimizeWithEarlierPrograms(t *testing.T) {
+	// as in the REPL, a function of the first program assigns to a global of
+	// the second
+	env := object.NewEnvironment()
+	e := evaluator.New()
+	var evaluated object.Object
+	for _, input := range []string{"let bump = fn() { n = n + 1 }", "let n = 1; bump(); n"} {
+		evaluated = e.Eval(Optimize(parser.New(lexer.New(input)).ParseProgram()), env)
+	}
+	if integer, ok := evaluated.(*object.Integer); !ok || integer.Value != 2 {
+		t.Errorf("expected 2. got=%T(%+v)", evaluated, evaluated)
+	}
+}
@@ -1,10 +1,13 @@
 package parser
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/shanehowearth/interpreter/ast"
+	"github.com/shanehowearth/interpreter/diagnostic"
 	"github.com/shanehowearth/interpreter/lexer"
+	"github.com/shanehowearth/interpreter/token"
 )
 
 func TestLetStatements(t *testing.T) {
@@ -123,6 +126,30 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "18446744073709551616;"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 1 {
+		t.Fatalf("program has not enough statements. got=%d",
+			len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+	literal, ok := stmt.Expression.(*ast.BigIntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.BigIntegerLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value.String() != "18446744073709551616" {
+		t.Errorf("literal.Value not %s. got=%s", "18446744073709551616", literal.Value)
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -178,6 +205,10 @@ func TestParsingInfixExpressions(t *testing.T) {
 		"<":              {"5 < 5", 5, "<", 5},
 		"==":             {"5 == 5", 5, "==", 5},
 		"!=":             {"5 != 5", 5, "!=", 5},
+		"<=":             {"5 <= 5", 5, "<=", 5},
+		">=":             {"5 >= 5", 5, ">=", 5},
+		"&&":             {"true && false", true, "&&", false},
+		"||":             {"true || false", true, "||", false},
 		"true == true":   {"true == true", true, "==", true},
 		"true != false":  {"true != false", true, "!=", false},
 		"false == false": {"false == false", false, "==", false},
@@ -223,33 +254,52 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		input    string
 		expected string
 	}{
-		"one":               {"-a * b", "((-a) * b)"},
-		"two":               {"!-a", "(!(-a))"},
-		"three":             {"a + b + c", "((a + b) + c)"},
-		"four":              {"a + b - c", "((a + b) - c)"},
-		"five":              {"a * b * c", "((a * b) * c)"},
-		"six":               {"a * b / c", "((a * b) / c)"},
-		"seven":             {"a + b / c", "(a + (b / c))"},
-		"eight":             {"a + b * c + d / e - f", "(((a + (b * c)) + (d / e)) - f)"},
-		"nine":              {"3 + 4; -5 * 5", "(3 + 4)((-5) * 5)"},
-		"ten":               {"5 > 4 == 3 < 4", "((5 > 4) == (3 < 4))"},
-		"eleven":            {"5 < 4 != 3 > 4", "((5 < 4) != (3 > 4))"},
-		"twelve":            {"3 + 4 * 5 == 3 * 1 + 4 * 5", "((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))"},
-		"thirteen":          {"3 + 4 * 5 == 3 * 1 + 4 * 5", "((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))"},
-		"true":              {"true", "true"},
-		"false":             {"false", "false"},
-		"'3 > 5' == false":  {"3 > 5 == false", "((3 > 5) == false)"},
-		"'3 < 5' == true":   {"3 < 5 == true", "((3 < 5) == true)"},
-		"grouped 01":        {"1 + (2 + 3) + 4", "((1 + (2 + 3)) + 4)"},
-		"grouped 02":        {"(5 + 5) * 2", "((5 + 5) * 2)"},
-		"grouped 03":        {"2 / (5 + 5)", "(2 / (5 + 5))"},
-		"grouped 04":        {"-(5 + 5)", "(-(5 + 5))"},
-		"grouped 05":        {"!(true == true)", "(!(true == true))"},
-		"call function 01":  {"a + add(b * c) + d", "((a + add((b * c))) + d)"},
-		"call function 02":  {"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))"},
-		"call function 03":  {"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
-		"First index demo":  {"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
-		"Second index demo": {"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		"one":                 {"-a * b", "((-a) * b)"},
+		"two":                 {"!-a", "(!(-a))"},
+		"three":               {"a + b + c", "((a + b) + c)"},
+		"four":                {"a + b - c", "((a + b) - c)"},
+		"five":                {"a * b * c", "((a * b) * c)"},
+		"six":                 {"a * b / c", "((a * b) / c)"},
+		"seven":               {"a + b / c", "(a + (b / c))"},
+		"eight":               {"a + b * c + d / e - f", "(((a + (b * c)) + (d / e)) - f)"},
+		"nine":                {"3 + 4; -5 * 5", "(3 + 4)((-5) * 5)"},
+		"ten":                 {"5 > 4 == 3 < 4", "((5 > 4) == (3 < 4))"},
+		"eleven":              {"5 < 4 != 3 > 4", "((5 < 4) != (3 > 4))"},
+		"twelve":              {"3 + 4 * 5 == 3 * 1 + 4 * 5", "((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))"},
+		"thirteen":            {"3 + 4 * 5 == 3 * 1 + 4 * 5", "((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))"},
+		"true":                {"true", "true"},
+		"false":               {"false", "false"},
+		"'3 > 5' == false":    {"3 > 5 == false", "((3 > 5) == false)"},
+		"'3 < 5' == true":     {"3 < 5 == true", "((3 < 5) == true)"},
+		"grouped 01":          {"1 + (2 + 3) + 4", "((1 + (2 + 3)) + 4)"},
+		"grouped 02":          {"(5 + 5) * 2", "((5 + 5) * 2)"},
+		"grouped 03":          {"2 / (5 + 5)", "(2 / (5 + 5))"},
+		"grouped 04":          {"-(5 + 5)", "(-(5 + 5))"},
+		"grouped 05":          {"!(true == true)", "(!(true == true))"},
+		"call function 01":    {"a + add(b * c) + d", "((a + add((b * c))) + d)"},
+		"call function 02":    {"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))"},
+		"call function 03":    {"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
+		"First index demo":    {"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		"Second index demo":   {"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		"less or equal":       {"a + 1 <= b * 2", "((a + 1) <= (b * 2))"},
+		"greater or equal":    {"a >= b == true", "((a >= b) == true)"},
+		"and over or":         {"a || b && c", "(a || (b && c))"},
+		"or chain":            {"a || b || c", "((a || b) || c)"},
+		"and over equals":     {"a == b && c != d", "((a == b) && (c != d))"},
+		"modulo":              {"a + b % c", "(a + (b % c))"},
+		"power over product":  {"a * b ** c", "(a * (b ** c))"},
+		"power right assoc":   {"a ** b ** c", "(a ** (b ** c))"},
+		"power over prefix":   {"-a ** b", "(-(a ** b))"},
+		"power of prefix":     {"a ** -b", "(a ** (-b))"},
+		"bitwise order":       {"a | b ^ c & d", "(a | (b ^ (c & d)))"},
+		"shift below sum":     {"a << b + c", "(a << (b + c))"},
+		"assign lowest":       {"x = a || b", "(x = (a || b))"},
+		"assign right assoc":  {"x = y += 5", "(x = (y += 5))"},
+		"compound assign":     {"x *= a + b", "(x *= (a + b))"},
+		"index assign":        {"a[i + 1] = b[j]", "((a[(i + 1)]) = (b[j]))"},
+		"nested index assign": {"a[i][j] -= 1", "(((a[i])[j]) -= 1)"},
+		"bitand over equal":   {"a & b == c", "((a & b) == c)"},
+		"tilde":               {"~a & b", "((~a) & b)"},
 	}
 
 	for name, tt := range tests {
@@ -310,6 +360,166 @@ func TestIfExpression(t *testing.T) {
 		t.Errorf("exp.Alternative.Statements was not nil. got=%+v", exp.Alternative)
 	}
 }
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < y) { x += 1; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Errorf("body is not %d statements. got=%d\n", 1, len(stmt.Body.Statements))
+	}
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (let i = 0; i < 10; i += 1) { puts(i) }", "for(let i = 0; (i < 10); (i += 1)) puts(i)"},
+		{"for (i = 0; i < 10; i += 1) { puts(i) }", "for((i = 0); (i < 10); (i += 1)) puts(i)"},
+		{"for (;;) { break; }", "for(; ; ) break;"},
+		{"for (; x;) { continue }", "for(; x; ) continue;"},
+		{"for (x in [1, 2]) { puts(x) }", "for(x in [1, 2]) puts(x)"},
+		{`for (c in "abc") { }`, "for(c in abc) "},
+		{"while (x) { };", "whilex "},
+		{"for (;;) { break };", "for(; ; ) break;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: program.Body does not contain %d statements. got=%d", tt.input, 1, len(program.Statements))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestTryAndThrow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { f() } catch (e) { g(e) }", "try f() catch(e) g(e)"},
+		{"try { f() } finally { g() }", "try f() finally g()"},
+		{"try { f() } catch (e) { g(e) } finally { h() }", "try f() catch(e) g(e) finally h()"},
+		{"let x = try { f() } catch (e) { 0 };", "let x = try f() catch(e) 0;"},
+		{`throw "bad input";`, "throw bad input;"},
+		{`throw {"message": "bad"}`, "throw (message:bad);"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: program.Body does not contain %d statements. got=%d", tt.input, 1, len(program.Statements))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := map[string]struct {
+		input            string
+		expectedExpected []token.TokenType
+	}{
+		"try without catch":  {"try { f() }; g()", []token.TokenType{token.CATCH, token.FINALLY}},
+		"catch without name": {"try { f() } catch { g() }", []token.TokenType{token.LPAREN}},
+		"catch with literal": {"try { f() } catch (1) { g() }", []token.TokenType{token.IDENT}},
+	}
+
+	for name, tt := range errorTests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Fatalf("%s: expected 1 diagnostic, got=%d %v", name, len(diagnostics), diagnostics)
+		}
+		if fmt.Sprint(diagnostics[0].Expected) != fmt.Sprint(tt.expectedExpected) {
+			t.Errorf("%s: wrong expected tokens. expected=%v, got=%v", name, tt.expectedExpected, diagnostics[0].Expected)
+		}
+	}
+}
+
+func TestLoopErrors(t *testing.T) {
+	tests := map[string]struct {
+		input        string
+		expectedCode diagnostic.Code
+	}{
+		"break outside loop":    {"break;", diagnostic.OutsideLoop},
+		"continue outside loop": {"if (x) { continue; }", diagnostic.OutsideLoop},
+		"break in function":     {"while (true) { fn() { break; } }", diagnostic.OutsideLoop},
+		"missing for semicolon": {"for (let i = 0 i < 3; i += 1) { }", diagnostic.UnexpectedToken},
+		"missing while paren":   {"while x { }", diagnostic.UnexpectedToken},
+	}
+
+	for name, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Fatalf("%s: expected 1 diagnostic, got=%d %v", name, len(diagnostics), diagnostics)
+		}
+		if diagnostics[0].Code != tt.expectedCode {
+			t.Errorf("%s: wrong code. expected=%s, got=%s", name, tt.expectedCode, diagnostics[0].Code)
+		}
+	}
+}
+
+func TestForHeaderErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (let i = 0 i < 3; i += 1) { }", `1:16: expected next token to be ";", got "IDENT" instead`},
+		{"for (let i = 0, i < 3; i += 1) { }", `1:15: expected next token to be ";", got "," instead`},
+		{"for (i = 0 i < 3; i += 1) { }", `1:12: expected next token to be ";", got "IDENT" instead`},
+		{"for (let i = 0; i < 3 i += 1) { }", `1:23: expected next token to be ";", got "IDENT" instead`},
+		{"for (let i = 0; i < 3; i += 1 { }", `1:31: expected next token to be ")", got "{" instead`},
+		{"for (;; i += 1 2) { }", `1:16: expected next token to be ")", got "INT" instead`},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%q: expected 1 error, got=%v", tt.input, errors)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
 func TestIfElseExpression(t *testing.T) {
 	input := `if (x < y) {x} else {y}`
 
@@ -408,6 +618,42 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
+func TestFunctionLiteralName(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedName string
+	}{
+		{"let add = fn(x, y) { x + y };", "add"},
+		{"fn(x, y) { x + y };", ""},
+		{"let apply = fn(f) { f() }(fn() { 1 });", ""},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var function ast.Expression
+		switch stmt := program.Statements[0].(type) {
+		case *ast.LetStatement:
+			function = stmt.Value
+		case *ast.ExpressionStatement:
+			function = stmt.Expression
+		}
+		if call, ok := function.(*ast.CallExpression); ok {
+			function = call.Function
+		}
+		literal, ok := function.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("%q: not *ast.FunctionLiteral. got=%T", tt.input, function)
+		}
+		if literal.Name != tt.expectedName {
+			t.Errorf("%q: wrong name. expected=%q, got=%q", tt.input, tt.expectedName, literal.Name)
+		}
+	}
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -549,13 +795,19 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		"two":   2,
 		"three": 3,
 	}
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for _, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
 		}
 		expectedValue := expected[literal.String()]
-		testIntegerLiteral(t, value, expectedValue)
+		testIntegerLiteral(t, pair.Value, expectedValue)
+	}
+	// the pairs are kept in the order they are written
+	for i, key := range []string{"one", "two", "three"} {
+		if i < len(hash.Pairs) && hash.Pairs[i].Key.String() != key {
+			t.Errorf("hash.Pairs[%d] has wrong key. expected=%q, got=%q", i, key, hash.Pairs[i].Key)
+		}
 	}
 }
 
@@ -600,10 +852,10 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 			testInfixExpression(t, e, 15, "/", 5)
 		},
 	}
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for _, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
 			continue
 		}
 		testFunc, ok := tests[literal.String()]
@@ -611,6 +863,189 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 			t.Errorf("No test function for key %q found", literal.String())
 			continue
 		}
-		testFunc(value)
+		testFunc(pair.Value)
+	}
+}
+
+func TestParserErrorPositions(t *testing.T) {
+	input := "let x = 5;\nlet = 10;"
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors, got none")
+	}
+	expected := `2:5: expected next token to be "IDENT", got "=" instead`
+	if errors[0] != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errors[0])
+	}
+}
+
+func TestParserDiagnostics(t *testing.T) {
+	input := "add(1, 2"
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) == 0 {
+		t.Fatalf("expected parser diagnostics, got none")
+	}
+	d := diagnostics[0]
+	if d.Severity != diagnostic.Error {
+		t.Errorf("d.Severity not Error. got=%s", d.Severity)
+	}
+	if d.Code != diagnostic.UnexpectedToken {
+		t.Errorf("d.Code not %s. got=%s", diagnostic.UnexpectedToken, d.Code)
+	}
+	if len(d.Expected) != 1 || d.Expected[0] != token.RPAREN {
+		t.Errorf("d.Expected not [%q]. got=%v", token.RPAREN, d.Expected)
+	}
+	if d.Actual != token.EOF {
+		t.Errorf("d.Actual not %q. got=%q", token.EOF, d.Actual)
+	}
+	if d.Pos().String() != "1:9" {
+		t.Errorf("d.Pos() not 1:9. got=%s", d.Pos())
+	}
+	if d.Hint == "" {
+		t.Errorf("d.Hint is empty")
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := map[string]struct {
+		input          string
+		expectedErrors int
+		expectedString string
+	}{
+		"return at EOF":       {"return 5", 0, "return 5;"},
+		"missing value":       {"let x = ;\nlet y = 2;", 1, "let y = 2;"},
+		"error in block":      {"if (x) { 1 + }\nlet z = 3;", 1, "ifx let z = 3;"},
+		"skip braced block":   {"let f = fn(a, 1) { a };\nlet g = 2;", 1, "let g = 2;"},
+		"one per statement":   {"let a = (1 + 2;\nlet b = [1, 2;\nlet c = 3;", 2, "let c = 3;"},
+		"unterminated block":  {"if (x) { 1", 1, ""},
+		"keep good functions": {"let f = fn() { let x = ; 5 };", 1, "let f = () 5;"},
+	}
+
+	for name, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != tt.expectedErrors {
+			t.Errorf("%s: wrong number of errors. expected=%d, got=%d %q",
+				name, tt.expectedErrors, len(p.Errors()), p.Errors())
+		}
+		if program.String() != tt.expectedString {
+			t.Errorf("%s: wrong program. expected=%q, got=%q",
+				name, tt.expectedString, program.String())
+		}
+	}
+}
+
+func TestIllegalTokenDiagnostics(t *testing.T) {
+	tests := map[string]struct {
+		input        string
+		expectedCode diagnostic.Code
+	}{
+		"illegal character":    {"let x = 5 @ 3;", diagnostic.IllegalCharacter},
+		"unterminated comment": {"let x = 5; /* never closed", diagnostic.UnterminatedComment},
+		"unterminated string":  {`let x = "never closed;`, diagnostic.UnterminatedString},
+		"invalid escape":       {`let x = "a \q b";`, diagnostic.InvalidEscape},
+	}
+
+	for name, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Fatalf("%s: expected 1 diagnostic, got=%d %v", name, len(diagnostics), diagnostics)
+		}
+		if diagnostics[0].Code != tt.expectedCode {
+			t.Errorf("%s: wrong code. expected=%s, got=%s", name, tt.expectedCode, diagnostics[0].Code)
+		}
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	tests := []string{
+		"5 = 3;",
+		"a + b = 3;",
+		"f() += 1;",
+	}
+
+	for _, input := range tests {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Fatalf("%q: expected 1 diagnostic, got=%d %v", input, len(diagnostics), diagnostics)
+		}
+		if diagnostics[0].Code != diagnostic.InvalidTarget {
+			t.Errorf("%q: wrong code. expected=%s, got=%s", input, diagnostic.InvalidTarget, diagnostics[0].Code)
+		}
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e3;", 1000},
+		{"2.5e-1;", 0.25},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("program has not enough statements. got=%d",
+				len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestInterpolatedStringParsing(t *testing.T) {
+	input := `"Hello ${name}, you have ${len(items) + 1} items"`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+	if len(str.Parts) != 5 {
+		t.Fatalf("wrong number of parts. got=%d", len(str.Parts))
+	}
+	if !testIdentifier(t, str.Parts[1], "name") {
+		return
+	}
+	expected := `"Hello ${name}, you have ${(len(items) + 1)} items"`
+	if str.String() != expected {
+		t.Errorf("str.String() wrong. expected=%q, got=%q", expected, str.String())
 	}
 }
@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/shanehowearth/interpreter/diagnostic"
 	"github.com/shanehowearth/interpreter/evaluator"
 	"github.com/shanehowearth/interpreter/lexer"
 	"github.com/shanehowearth/interpreter/object"
+	"github.com/shanehowearth/interpreter/optimizer"
 	"github.com/shanehowearth/interpreter/parser"
 )
 
@@ -28,8 +30,36 @@ const monkeyFace = `
           '-----'
 `
 
+// Option - configures the REPL
+type Option func(*config)
+
+type config struct {
+	optimize bool
+	dump     bool
+}
+
+// WithOptimizer - optimize each line before it is run
+func WithOptimizer() Option {
+	return func(c *config) {
+		c.optimize = true
+	}
+}
+
+// WithDump - print each line once it has been optimized, before it is run
+func WithDump() Option {
+	return func(c *config) {
+		c.optimize = true
+		c.dump = true
+	}
+}
+
 // Start -
-func Start(in io.Reader, out io.Writer) {
+func Start(in io.Reader, out io.Writer, opts ...Option) {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
 	for {
@@ -45,12 +75,22 @@ func Start(in io.Reader, out io.Writer) {
 
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			printParserErrors(out, line, p.Diagnostics())
 			continue
 		}
+		if c.optimize {
+			program = optimizer.Optimize(program)
+		}
+		if c.dump {
+			io.WriteString(out, program.String())
+			io.WriteString(out, "\n")
+		}
 
-		if evaluated := evaluator.Eval(program, env); evaluated != nil {
-
+		evaluated := evaluator.Eval(program, env)
+		if err, ok := evaluated.(*object.Error); ok {
+			io.WriteString(out, err.Traceback())
+			io.WriteString(out, "\n")
+		} else if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
 		}
@@ -58,11 +98,11 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
+func printParserErrors(out io.Writer, source string, diagnostics []diagnostic.Diagnostic) {
 	io.WriteString(out, monkeyFace)
 	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
 	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for _, d := range diagnostics {
+		diagnostic.Render(out, source, d)
 	}
 }
@@ -0,0 +1,24 @@
+package resolver
+
+import "github.com/shanehowearth/interpreter/ast"
+
+// declare gives the variables bound by the let statements in node slots in
+// s, before any of them is resolved. The bodies of functions, for loops and
+// catch blocks have scopes of their own and are declared when they are
+// resolved.
+func declare(s *scope, node ast.Node) {
+	switch node := node.(type) {
+	case *ast.FunctionLiteral, *ast.ForStatement:
+		return
+	case *ast.ForInStatement:
+		declare(s, node.Iterable)
+		return
+	case *ast.TryExpression:
+		declare(s, node.Block)
+		declare(s, node.Finally)
+		return
+	case *ast.LetStatement:
+		s.define(node.Name)
+	}
+	ast.Children(node, func(child ast.Node) { declare(s, child) })
+}
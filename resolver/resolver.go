@@ -0,0 +1,280 @@
+// Package resolver - finds the variable each identifier of a program refers
+// to before the program is run
+package resolver
+
+import (
+	"fmt"
+
+	"github.com/shanehowearth/interpreter/ast"
+	"github.com/shanehowearth/interpreter/diagnostic"
+)
+
+// scope is a function body, a for loop, an iteration of a for in loop or a
+// catch block, each of which the evaluator gives a frame of its own, or the
+// program itself. Blocks of if, while and try share the scope they are in.
+type scope struct {
+	outer    *scope
+	global   bool
+	function bool
+
+	slots    map[string]int             // every variable of the scope, with its slot
+	lets     map[string]*ast.Identifier // where each variable is first declared
+	declared map[string]bool            // the variables that have been bound so far
+	size     int
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{
+		outer:    outer,
+		slots:    make(map[string]int),
+		lets:     make(map[string]*ast.Identifier),
+		declared: make(map[string]bool),
+	}
+}
+
+// define gives name a slot, a name declared twice in a scope has one slot
+func (s *scope) define(ident *ast.Identifier) {
+	if _, ok := s.slots[ident.Value]; ok {
+		return
+	}
+	s.slots[ident.Value] = s.size
+	s.lets[ident.Value] = ident
+	s.size++
+}
+
+// defineParameter gives a parameter the next slot, the arguments are put in
+// the slots of the parameters in order
+func (s *scope) defineParameter(ident *ast.Identifier) {
+	s.slots[ident.Value] = s.size
+	s.lets[ident.Value] = ident
+	s.declared[ident.Value] = true
+	s.size++
+}
+
+type resolver struct {
+	scope       *scope
+	globals     func(name string) bool // whether an earlier program bound name
+	diagnostics []diagnostic.Diagnostic
+}
+
+// Option - configures Resolve
+type Option func(*resolver)
+
+// WithGlobals - bound reports whether name is a global bound by an earlier
+// program run in the same environment, as in the REPL. Until the program
+// binds such a name again a use of it is the earlier variable, rather than a
+// use before its declaration.
+func WithGlobals(bound func(name string) bool) Option {
+	return func(r *resolver) {
+		r.globals = bound
+	}
+}
+
+// Resolve - annotates the identifiers of program that refer to local
+// variables with the frame and the slot that holds the variable, and reports
+// the variables used before they are declared. A variable declared later in
+// a scope is visible from the functions created in it before then, which
+// can only be called once it is declared, as in mutual recursion.
+func Resolve(program *ast.Program, opts ...Option) []diagnostic.Diagnostic {
+	r := &resolver{scope: newScope(nil)}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.scope.global = true
+	for _, stmt := range program.Statements {
+		declare(r.scope, stmt)
+	}
+	for _, stmt := range program.Statements {
+		r.resolve(stmt)
+	}
+	return r.diagnostics
+}
+
+// body declares the variables of the block that starts the innermost scope,
+// then resolves it
+func (r *resolver) body(block *ast.BlockStatement) {
+	declare(r.scope, block)
+	r.resolve(block)
+}
+
+func (r *resolver) resolve(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		if node == nil {
+			return
+		}
+		for _, stmt := range node.Statements {
+			r.resolve(stmt)
+		}
+	case *ast.LetStatement:
+		r.resolve(node.Value)
+		r.bind(node.Name)
+	case *ast.ExpressionStatement:
+		r.resolve(node.Expression)
+	case *ast.ReturnStatement:
+		r.resolve(node.ReturnValue)
+	case *ast.ThrowStatement:
+		r.resolve(node.Value)
+	case *ast.WhileStatement:
+		r.resolve(node.Condition)
+		r.resolve(node.Body)
+	case *ast.ForStatement:
+		r.resolveFor(node)
+	case *ast.ForInStatement:
+		r.resolve(node.Iterable)
+		r.enter(false)
+		r.scope.defineParameter(node.Variable)
+		r.bind(node.Variable)
+		r.body(node.Body)
+		node.Slots = r.leave()
+	case *ast.Identifier:
+		r.use(node)
+	case *ast.PrefixExpression:
+		r.resolve(node.Right)
+	case *ast.InfixExpression:
+		r.resolve(node.Left)
+		r.resolve(node.Right)
+	case *ast.AssignExpression:
+		r.resolve(node.Target)
+		r.resolve(node.Value)
+	case *ast.IfExpression:
+		r.resolve(node.Condition)
+		r.resolve(node.Consequence)
+		r.resolve(node.Alternative)
+	case *ast.TryExpression:
+		r.resolveTry(node)
+	case *ast.FunctionLiteral:
+		r.enter(true)
+		for _, param := range node.Parameters {
+			r.scope.defineParameter(param)
+		}
+		for _, param := range node.Parameters {
+			r.bind(param)
+		}
+		r.body(node.Body)
+		node.Slots = r.leave()
+	case *ast.CallExpression:
+		r.resolve(node.Function)
+		for _, arg := range node.Arguments {
+			r.resolve(arg)
+		}
+	case *ast.IndexExpression:
+		r.resolve(node.Left)
+		r.resolve(node.Index)
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			r.resolve(element)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			r.resolve(pair.Key)
+			r.resolve(pair.Value)
+		}
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			r.resolve(part)
+		}
+	}
+}
+
+// resolveFor resolves a C style for loop in the order it is run, the
+// variables of its init and its body share the frame of the loop
+func (r *resolver) resolveFor(node *ast.ForStatement) {
+	r.enter(false)
+	if node.Init != nil {
+		declare(r.scope, node.Init)
+	}
+	declare(r.scope, node.Body)
+	if node.Init != nil {
+		r.resolve(node.Init)
+	}
+	if node.Condition != nil {
+		r.resolve(node.Condition)
+	}
+	r.resolve(node.Body)
+	if node.Update != nil {
+		r.resolve(node.Update)
+	}
+	node.Slots = r.leave()
+}
+
+// resolveTry resolves a try expression, the catch block has a frame of its
+// own for the error and its variables
+func (r *resolver) resolveTry(node *ast.TryExpression) {
+	r.resolve(node.Block)
+	if node.Catch != nil {
+		r.enter(false)
+		r.scope.defineParameter(node.CatchParam)
+		r.bind(node.CatchParam)
+		r.body(node.Catch)
+		node.CatchSlots = r.leave()
+	}
+	r.resolve(node.Finally)
+}
+
+func (r *resolver) enter(function bool) {
+	r.scope = newScope(r.scope)
+	r.scope.function = function
+}
+
+// leave ends the innermost scope, returning the number of slots it needs
+func (r *resolver) leave() int {
+	size := r.scope.size
+	r.scope = r.scope.outer
+	return size
+}
+
+// bind marks the variable ident declares as bound in the innermost scope
+func (r *resolver) bind(ident *ast.Identifier) {
+	s := r.scope
+	s.declared[ident.Value] = true
+	annotate(ident, s, 0)
+}
+
+// use resolves an identifier that is read or assigned to. The variable is the
+// innermost one bound at that point, or one bound later in a scope the use is
+// in a function of. Being bound later in the scope the use is in, and not in
+// any scope around it, is an error, unless it is a global an earlier program
+// bound. Any other name is a global or a builtin.
+func (r *resolver) use(ident *ast.Identifier) {
+	var later *ast.Identifier
+	laterGlobal := false
+	inFunction := false
+	depth := 0
+	for s := r.scope; s != nil; s = s.outer {
+		if _, ok := s.slots[ident.Value]; ok {
+			if s.declared[ident.Value] || inFunction {
+				annotate(ident, s, depth)
+				return
+			}
+			if later == nil {
+				later = s.lets[ident.Value]
+				laterGlobal = s.global
+			}
+		}
+		inFunction = inFunction || s.function
+		depth++
+	}
+
+	ident.Local = false
+	if later != nil && !(laterGlobal && r.globals != nil && r.globals(ident.Value)) {
+		r.diagnostics = append(r.diagnostics, diagnostic.Diagnostic{
+			Severity: diagnostic.Error,
+			Code:     diagnostic.UseBeforeDeclaration,
+			Span:     ident.Token.Span,
+			Message:  fmt.Sprintf("use of %s before its declaration", ident.Value),
+			Hint:     fmt.Sprintf("%s is declared at %s", ident.Value, later.Pos()),
+		})
+	}
+}
+
+// annotate points ident at its slot in s, which is depth frames out
+func annotate(ident *ast.Identifier, s *scope, depth int) {
+	if s.global {
+		ident.Local = false
+		return
+	}
+	ident.Local = true
+	ident.Depth = depth
+	ident.Slot = s.slots[ident.Value]
+}
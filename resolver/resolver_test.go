@@ -0,0 +1,194 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/shanehowearth/interpreter/ast"
+	"github.com/shanehowearth/interpreter/diagnostic"
+	"github.com/shanehowearth/interpreter/lexer"
+	"github.com/shanehowearth/interpreter/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%q: parser errors: %v", input, p.Errors())
+	}
+	return program
+}
+
+// identifiers returns the identifiers of node named name, in the order they
+// appear in the source
+func identifiers(node ast.Node, name string) []*ast.Identifier {
+	var found []*ast.Identifier
+	var walk func(node ast.Node)
+	walk = func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.Program:
+			for _, stmt := range node.Statements {
+				walk(stmt)
+			}
+		case *ast.BlockStatement:
+			for _, stmt := range node.Statements {
+				walk(stmt)
+			}
+		case *ast.LetStatement:
+			walk(node.Name)
+			walk(node.Value)
+		case *ast.ExpressionStatement:
+			walk(node.Expression)
+		case *ast.ReturnStatement:
+			walk(node.ReturnValue)
+		case *ast.ForInStatement:
+			walk(node.Variable)
+			walk(node.Iterable)
+			walk(node.Body)
+		case *ast.Identifier:
+			if node.Value == name {
+				found = append(found, node)
+			}
+		case *ast.InfixExpression:
+			walk(node.Left)
+			walk(node.Right)
+		case *ast.AssignExpression:
+			walk(node.Target)
+			walk(node.Value)
+		case *ast.FunctionLiteral:
+			for _, param := range node.Parameters {
+				walk(param)
+			}
+			walk(node.Body)
+		case *ast.CallExpression:
+			walk(node.Function)
+			for _, arg := range node.Arguments {
+				walk(arg)
+			}
+		}
+	}
+	walk(node)
+	return found
+}
+
+func TestResolve(t *testing.T) {
+	type slot struct {
+		local       bool
+		depth, slot int
+	}
+	tests := []struct {
+		input    string
+		name     string
+		expected []slot
+	}{
+		{"let a = 1; a", "a", []slot{{}, {}}},
+		{"let f = fn(a, b) { a + b }", "b", []slot{{true, 0, 1}, {true, 0, 1}}},
+		{"let f = fn(a) { let b = a; fn() { a + b } }", "a", []slot{{true, 0, 0}, {true, 0, 0}, {true, 1, 0}}},
+		{"let f = fn(a) { let b = a; fn() { a + b } }", "b", []slot{{true, 0, 1}, {true, 1, 1}}},
+		{"let f = fn() { for (x in [1]) { x } }", "x", []slot{{true, 0, 0}, {true, 0, 0}}},
+		// the x of the function is not bound when y is, y is given the global
+		{"let x = 1; let f = fn() { let y = x; let x = 2; y }", "x", []slot{{}, {}, {true, 0, 1}}},
+		// a function can use a variable bound after it is created
+		{"let f = fn() { let g = fn() { h() }; let h = fn() { 1 }; g() }", "h", []slot{{true, 1, 1}, {true, 0, 1}}},
+		{"len([])", "len", []slot{{}}},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		if diagnostics := Resolve(program); len(diagnostics) != 0 {
+			t.Errorf("%q: unexpected diagnostics: %v", tt.input, diagnostics)
+			continue
+		}
+		found := identifiers(program, tt.name)
+		if len(found) != len(tt.expected) {
+			t.Errorf("%q: expected %d uses of %s, got=%d", tt.input, len(tt.expected), tt.name, len(found))
+			continue
+		}
+		for i, ident := range found {
+			got := slot{ident.Local, ident.Depth, ident.Slot}
+			if !ident.Local {
+				got = slot{}
+			}
+			if got != tt.expected[i] {
+				t.Errorf("%q: %s at %s resolved to %+v, expected=%+v",
+					tt.input, tt.name, ident.Pos(), got, tt.expected[i])
+			}
+		}
+	}
+}
+
+func TestFrameSizes(t *testing.T) {
+	program := parse(t, `let f = fn(a) {
+  let b = a;
+  for (let i = 0; i < 1; i += 1) { let j = i }
+  for (x in [1]) { let y = x; let z = y }
+  try { 1 } catch (e) { let m = e };
+  if (b) { let c = 1 }
+}`)
+	if diagnostics := Resolve(program); len(diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diagnostics)
+	}
+
+	fn := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if fn.Slots != 3 {
+		t.Errorf("function slots wrong. expected=3, got=%d", fn.Slots)
+	}
+	body := fn.Body.Statements
+	if slots := body[1].(*ast.ForStatement).Slots; slots != 2 {
+		t.Errorf("for slots wrong. expected=2, got=%d", slots)
+	}
+	if slots := body[2].(*ast.ForInStatement).Slots; slots != 3 {
+		t.Errorf("for in slots wrong. expected=3, got=%d", slots)
+	}
+	try := body[3].(*ast.ExpressionStatement).Expression.(*ast.TryExpression)
+	if try.CatchSlots != 2 {
+		t.Errorf("catch slots wrong. expected=2, got=%d", try.CatchSlots)
+	}
+}
+
+func TestUseBeforeDeclaration(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos string
+		hint        string
+	}{
+		{"x; let x = 1", "1:1", "x is declared at 1:8"},
+		{"let f = fn() {\n  let y = x;\n  let x = 2\n}", "2:11", "x is declared at 3:7"},
+		{"let f = fn() { x += 1; let x = 2 }", "1:16", "x is declared at 1:28"},
+		{"let x = x + 1", "1:9", "x is declared at 1:5"},
+		{"for (let i = 0; i < 1; i += 1) { j; let j = 1 }", "1:34", "j is declared at 1:41"},
+	}
+	for _, tt := range tests {
+		diagnostics := Resolve(parse(t, tt.input))
+		if len(diagnostics) != 1 {
+			t.Errorf("%q: expected 1 diagnostic, got=%v", tt.input, diagnostics)
+			continue
+		}
+		d := diagnostics[0]
+		if d.Severity != diagnostic.Error || d.Code != diagnostic.UseBeforeDeclaration {
+			t.Errorf("%q: wrong diagnostic. got=%s", tt.input, d)
+		}
+		if d.Pos().String() != tt.expectedPos {
+			t.Errorf("%q: wrong position. expected=%s, got=%s", tt.input, tt.expectedPos, d.Pos())
+		}
+		if d.Hint != tt.hint {
+			t.Errorf("%q: wrong hint. expected=%q, got=%q", tt.input, tt.hint, d.Hint)
+		}
+	}
+
+	// a global bound by an earlier program is that variable until it is bound
+	// again, a local is not
+	earlier := WithGlobals(func(name string) bool { return name == "x" })
+	if diagnostics := Resolve(parse(t, "let x = x + 1"), earlier); len(diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics: %v", diagnostics)
+	}
+	if diagnostics := Resolve(parse(t, "let f = fn() { x; let x = 2 }"), earlier); len(diagnostics) != 1 {
+		t.Errorf("expected 1 diagnostic, got=%v", diagnostics)
+	}
+
+	// names that are never declared are globals or builtins, found when the
+	// program is run
+	if diagnostics := Resolve(parse(t, "let f = fn() { g() }; foo; len")); len(diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics: %v", diagnostics)
+	}
+}
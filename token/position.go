@@ -0,0 +1,38 @@
+package token
+
+import "fmt"
+
+// Position - a location in the source
+// Line and Column start at 1, Offset is the byte offset starting at 0
+// A Position with a Line of 0 is not valid, i.e. it does not point anywhere
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int // measured in runes
+}
+
+// IsValid -
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String - file:line:column, or line:column when there is no file name
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+	if p.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Span - the source covered by a token, End is the position immediately after it
+type Span struct {
+	Start Position
+	End   Position
+}
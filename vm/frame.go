@@ -0,0 +1,53 @@
+package vm
+
+import (
+	"github.com/shanehowearth/interpreter/code"
+	"github.com/shanehowearth/interpreter/object"
+	"github.com/shanehowearth/interpreter/token"
+)
+
+// Frame - a call being executed
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int           // the stack pointer before the function and its arguments were pushed
+	scope       *object.Scope // the innermost scope, that of the call or a block in it
+	pos         token.Position
+}
+
+// NewFrame -
+func NewFrame(cl *object.Closure, basePointer int, scope *object.Scope, pos token.Position) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer, scope: scope, pos: pos}
+}
+
+// Instructions -
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
+
+// handler is where execution continues when an error is raised in a try
+// block, with the stack and the scope as they were when it was entered
+type handler struct {
+	frame int
+	sp    int
+	scope *object.Scope
+	ip    int
+}
+
+// iterator is the state of a for in loop
+type iterator struct {
+	values []object.Object
+	next   int
+}
+
+// Type -
+func (it *iterator) Type() object.ObjectType { return "ITERATOR" }
+
+// Inspect -
+func (it *iterator) Inspect() string { return "iterator" }
+
+// Equals -
+func (it *iterator) Equals(other object.Object) bool {
+	o, ok := other.(*iterator)
+	return ok && o == it
+}
@@ -0,0 +1,492 @@
+// Package vm - executes the bytecode of a compiled program, with the same
+// meaning as the evaluator gives the program
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/shanehowearth/interpreter/code"
+	"github.com/shanehowearth/interpreter/compiler"
+	"github.com/shanehowearth/interpreter/evaluator"
+	"github.com/shanehowearth/interpreter/object"
+	"github.com/shanehowearth/interpreter/token"
+)
+
+// StackSize - the initial size of the stack, it grows as it is needed
+const StackSize = 2048
+
+// ErrInvalidBytecode - the Cause of the error a run stops with on bytecode the
+// compiler does not produce, such as an unknown opcode
+var ErrInvalidBytecode = errors.New("invalid bytecode")
+
+var operators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpPow:          "**",
+	code.OpBitAnd:       "&",
+	code.OpBitOr:        "|",
+	code.OpBitXor:       "^",
+	code.OpShiftLeft:    "<<",
+	code.OpShiftRight:   ">>",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpLessThan:     "<",
+	code.OpGreaterThan:  ">",
+	code.OpLessEqual:    "<=",
+	code.OpGreaterEqual: ">=",
+	code.OpMinus:        "-",
+	code.OpBang:         "!",
+	code.OpBitNot:       "~",
+}
+
+// VM - runs a compiled program. The operators, the builtins and the limits on
+// a run are those of an evaluator.Evaluator with the same options. A VM is not
+// safe for concurrent use.
+type VM struct {
+	constants []object.Object
+	globals   []object.Object
+	main      *object.Closure
+	rt        *evaluator.Evaluator
+
+	stack    []object.Object
+	sp       int // the next free slot, the top of the stack is stack[sp-1]
+	frames   []*Frame
+	handlers []handler
+}
+
+// New -
+func New(bytecode *compiler.Bytecode, opts ...evaluator.Option) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Positions:    bytecode.Positions,
+		Names:        bytecode.Names,
+	}
+	return &VM{
+		constants: bytecode.Constants,
+		main:      &object.Closure{Fn: mainFn},
+		rt:        evaluator.New(opts...),
+		stack:     make([]object.Object, StackSize),
+	}
+}
+
+// Run - runs the program, without a deadline
+func (vm *VM) Run() object.Object {
+	return vm.RunContext(context.Background())
+}
+
+// RunContext - runs the program, returning its value, which is an error if
+// one was raised and not caught. The run stops once ctx is done, as
+// evaluator.EvalContext does.
+func (vm *VM) RunContext(ctx context.Context) object.Object {
+	vm.rt.Start(ctx)
+	vm.sp = 0
+	vm.frames = []*Frame{NewFrame(vm.main, 0, nil, token.Position{})}
+	vm.handlers = nil
+
+	for {
+		frame := vm.frames[len(vm.frames)-1]
+		frame.ip++
+		ip := frame.ip
+		ins := frame.Instructions()
+		op := code.Opcode(ins[ip])
+
+		var err object.Object
+		switch op {
+		case code.OpConstant:
+			idx := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			vm.push(vm.constants[idx])
+		case code.OpPop:
+			vm.pop()
+		case code.OpTrue:
+			vm.push(evaluator.TRUE)
+		case code.OpFalse:
+			vm.push(evaluator.FALSE)
+		case code.OpNull:
+			vm.push(evaluator.NULL)
+		case code.OpNil:
+			vm.push(nil)
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight,
+			code.OpEqual, code.OpNotEqual, code.OpLessThan, code.OpGreaterThan,
+			code.OpLessEqual, code.OpGreaterEqual:
+			right := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(vm.rt.Infix(operators[op], left, right))
+		case code.OpMinus, code.OpBang, code.OpBitNot:
+			err = vm.pushResult(vm.rt.Prefix(operators[op], vm.pop()))
+
+		case code.OpJump:
+			frame.ip = int(code.ReadUint16(ins[ip+1:])) - 1
+		case code.OpJumpNotTruthy, code.OpJumpTruthy:
+			frame.ip += 2
+			if evaluator.IsTruthy(vm.pop()) == (op == code.OpJumpTruthy) {
+				frame.ip = int(code.ReadUint16(ins[ip+1:])) - 1
+			}
+
+		case code.OpGetGlobal:
+			idx := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			err = vm.pushVariable(vm.global(idx), frame, ip)
+		case code.OpSetGlobal:
+			idx := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			vm.setGlobal(idx, vm.pop())
+		case code.OpAssignGlobal:
+			idx := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			if vm.global(idx) == nil {
+				err = undeclared(frame, ip)
+				break
+			}
+			vm.setGlobal(idx, vm.stack[vm.sp-1])
+		case code.OpGetLocal:
+			scope := frame.outerScope(int(code.ReadUint16(ins[ip+1:])))
+			idx := code.ReadUint16(ins[ip+3:])
+			frame.ip += 4
+			err = vm.pushVariable(scope.Vars[idx], frame, ip)
+		case code.OpSetLocal:
+			scope := frame.outerScope(int(code.ReadUint16(ins[ip+1:])))
+			idx := code.ReadUint16(ins[ip+3:])
+			frame.ip += 4
+			scope.Vars[idx] = vm.pop()
+		case code.OpAssignLocal:
+			scope := frame.outerScope(int(code.ReadUint16(ins[ip+1:])))
+			idx := code.ReadUint16(ins[ip+3:])
+			frame.ip += 4
+			if scope.Vars[idx] == nil {
+				err = undeclared(frame, ip)
+				break
+			}
+			scope.Vars[idx] = vm.stack[vm.sp-1]
+		case code.OpGetBuiltin:
+			idx := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			name := vm.constants[idx].(*object.String).Value
+			if builtin, ok := evaluator.Builtin(name); ok {
+				vm.push(builtin)
+				break
+			}
+			err = newError("identifier not found: %s", name)
+		case code.OpPushScope:
+			size := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			frame.scope = object.NewScope(size, frame.scope)
+		case code.OpPopScope:
+			frame.scope = frame.scope.Outer
+
+		case code.OpArray:
+			n := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			elements := vm.popValues(n)
+			err = vm.pushResult(vm.rt.Allocate(&object.Array{Elements: elements}))
+		case code.OpHash:
+			n := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			values := vm.popValues(n)
+			pairs := make([]object.HashPair, 0, n/2)
+			for i := 0; i < n; i += 2 {
+				pairs = append(pairs, object.HashPair{Key: values[i], Value: values[i+1]})
+			}
+			err = vm.pushResult(vm.rt.Hash(pairs))
+		case code.OpInterpolate:
+			n := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			err = vm.pushResult(vm.rt.Interpolate(vm.popValues(n)))
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(vm.rt.Index(left, index))
+		case code.OpIndexKeep:
+			err = vm.pushResult(vm.rt.Index(vm.stack[vm.sp-2], vm.stack[vm.sp-1]))
+		case code.OpSetIndex:
+			val := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(vm.rt.SetIndex(left, index, val))
+
+		case code.OpClosure:
+			idx := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			fn, ok := vm.constants[idx].(*object.CompiledFunction)
+			if !ok {
+				err = invalid("constant %d is not a function", idx)
+				break
+			}
+			vm.push(&object.Closure{Fn: fn, Scope: frame.scope})
+		case code.OpCall:
+			numArgs := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			err = vm.call(numArgs, frame.cl.Fn.Positions[ip])
+		case code.OpTailCall:
+			numArgs := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			err = vm.tailCall(numArgs, frame.cl.Fn.Positions[ip])
+		case code.OpReturnValue:
+			val := vm.pop()
+			if len(vm.frames) == 1 {
+				return val
+			}
+			vm.returnValue(val)
+
+		case code.OpStep:
+			if stopped := vm.rt.Step(); stopped != nil {
+				err = stopped
+			}
+		case code.OpIterate:
+			values, iterErr := vm.rt.Iterate(vm.pop())
+			if iterErr != nil {
+				err = iterErr
+				break
+			}
+			vm.push(&iterator{values: values})
+		case code.OpIterNext:
+			frame.ip += 2
+			it := vm.pop().(*iterator)
+			if it.next == len(it.values) {
+				frame.ip = int(code.ReadUint16(ins[ip+1:])) - 1
+				break
+			}
+			vm.push(it.values[it.next])
+			it.next++
+
+		case code.OpSetupTry:
+			frame.ip += 2
+			vm.handlers = append(vm.handlers, handler{
+				frame: len(vm.frames) - 1,
+				sp:    vm.sp,
+				scope: frame.scope,
+				ip:    int(code.ReadUint16(ins[ip+1:])),
+			})
+		case code.OpPopTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case code.OpCatch:
+			err = vm.pushResult(vm.rt.Catch(vm.pop().(*object.Error)))
+		case code.OpThrow:
+			err = vm.rt.Throw(vm.pop())
+		case code.OpRethrow:
+			err = vm.pop()
+		case code.OpError:
+			idx := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			err = newError("%s", vm.constants[idx].(*object.String).Value)
+
+		default:
+			err = invalid("unknown opcode %d", op)
+		}
+
+		if err != nil && vm.raise(err.(*object.Error), ip) {
+			return err
+		}
+	}
+}
+
+// call calls the function below the arguments on top of the stack, from pos.
+// A compiled function gets a new frame with a scope holding the arguments, a
+// builtin is called at once and its result pushed.
+func (vm *VM) call(numArgs int, pos token.Position) object.Object {
+	if err := vm.rt.Step(); err != nil {
+		return err
+	}
+
+	basePointer := vm.sp - numArgs - 1
+	switch fn := vm.stack[basePointer].(type) {
+	case *object.Closure:
+		if numArgs != fn.Fn.NumParameters {
+			return newError("wrong number of arguments: want=%d, got=%d", fn.Fn.NumParameters, numArgs)
+		}
+		if max := vm.rt.MaxDepth(); max > 0 && len(vm.frames)-1 >= max {
+			err := newError("maximum recursion depth exceeded")
+			err.Stack = append(vm.callStack(), object.Frame{Function: fn.Fn.Name, Pos: pos})
+			return err
+		}
+
+		scope := object.NewScope(fn.Fn.NumLocals, fn.Scope)
+		copy(scope.Vars[:fn.Fn.NumParameters], vm.stack[basePointer+1:vm.sp])
+		vm.sp = basePointer
+		vm.frames = append(vm.frames, NewFrame(fn, basePointer, scope, pos))
+		return nil
+	case *object.Builtin:
+		args := vm.popValues(numArgs)
+		vm.pop()
+		return vm.pushResult(vm.rt.CallBuiltin(fn, args))
+	default:
+		return newError("not a function: %s", fn.Type())
+	}
+}
+
+// tailCall makes a call in tail position, the frame of the caller is taken
+// off first so that the call does not add to the depth
+func (vm *VM) tailCall(numArgs int, pos token.Position) object.Object {
+	frame := vm.frames[len(vm.frames)-1]
+	values := vm.popValues(numArgs + 1)
+	vm.frames = vm.frames[:len(vm.frames)-1]
+	vm.sp = frame.basePointer
+	for _, value := range values {
+		vm.push(value)
+	}
+
+	err := vm.call(numArgs, pos)
+	if err, ok := err.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = pos
+	}
+	return err
+}
+
+// returnValue leaves the current call with val
+func (vm *VM) returnValue(val object.Object) {
+	frame := vm.frames[len(vm.frames)-1]
+	vm.frames = vm.frames[:len(vm.frames)-1]
+	for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].frame >= len(vm.frames) {
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	}
+	vm.sp = frame.basePointer
+	vm.push(val)
+}
+
+// raise handles err, raised by the instruction at ip. The error is given the
+// position of the instruction, or else that of the innermost call it leaves,
+// and the calls being made when it was raised. The innermost handler takes it
+// unless it stopped the run, the result is true when there is none to take it
+// and the run is over.
+func (vm *VM) raise(err *object.Error, ip int) bool {
+	frame := vm.frames[len(vm.frames)-1]
+	if !err.Pos.IsValid() {
+		if pos, ok := frame.cl.Fn.Positions[ip]; ok {
+			err.Pos = pos
+		}
+	}
+	if err.Stack == nil && len(vm.frames) > 1 {
+		err.Stack = vm.callStack()
+	}
+
+	if err.Cause != nil || len(vm.handlers) == 0 {
+		vm.unwind(err, 0)
+		return true
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	vm.unwind(err, h.frame)
+	frame = vm.frames[len(vm.frames)-1]
+	frame.scope = h.scope
+	frame.ip = h.ip - 1
+	vm.sp = h.sp
+	vm.push(err)
+	return false
+}
+
+// unwind takes the calls above the frame at index off the stack
+func (vm *VM) unwind(err *object.Error, index int) {
+	for len(vm.frames)-1 > index {
+		if !err.Pos.IsValid() {
+			err.Pos = vm.frames[len(vm.frames)-1].pos
+		}
+		vm.frames = vm.frames[:len(vm.frames)-1]
+	}
+}
+
+// callStack is the calls being made, outermost first
+func (vm *VM) callStack() []object.Frame {
+	stack := make([]object.Frame, 0, len(vm.frames)-1)
+	for _, frame := range vm.frames[1:] {
+		stack = append(stack, object.Frame{Function: frame.cl.Fn.Name, Pos: frame.pos})
+	}
+	return stack
+}
+
+// pushVariable pushes the value of a variable, one that has not been bound
+// yet is a builtin of the same name, if there is one
+func (vm *VM) pushVariable(val object.Object, frame *Frame, ip int) object.Object {
+	if val != nil {
+		vm.push(val)
+		return nil
+	}
+	name := frame.cl.Fn.Names[ip]
+	if builtin, ok := evaluator.Builtin(name); ok {
+		vm.push(builtin)
+		return nil
+	}
+	return newError("identifier not found: %s", name)
+}
+
+func undeclared(frame *Frame, ip int) *object.Error {
+	return newError("assignment to undeclared variable: %s", frame.cl.Fn.Names[ip])
+}
+
+func (vm *VM) global(idx int) object.Object {
+	if idx >= len(vm.globals) {
+		return nil
+	}
+	return vm.globals[idx]
+}
+
+func (vm *VM) setGlobal(idx int, val object.Object) {
+	for idx >= len(vm.globals) {
+		vm.globals = append(vm.globals, nil)
+	}
+	vm.globals[idx] = val
+}
+
+// outerScope is the scope depth scopes out from the current one
+func (f *Frame) outerScope(depth int) *object.Scope {
+	scope := f.scope
+	for ; depth > 0; depth-- {
+		scope = scope.Outer
+	}
+	return scope
+}
+
+// pushResult pushes the result of an operation, unless it is an error, which
+// is returned instead
+func (vm *VM) pushResult(obj object.Object) object.Object {
+	if err, ok := obj.(*object.Error); ok {
+		return err
+	}
+	vm.push(obj)
+	return nil
+}
+
+func (vm *VM) push(o object.Object) {
+	if vm.sp == len(vm.stack) {
+		vm.stack = append(vm.stack, make([]object.Object, len(vm.stack))...)
+	}
+	vm.stack[vm.sp] = o
+	vm.sp++
+}
+
+func (vm *VM) pop() object.Object {
+	vm.sp--
+	o := vm.stack[vm.sp]
+	vm.stack[vm.sp] = nil
+	return o
+}
+
+// popValues pops n values, returning them in the order they were pushed
+func (vm *VM) popValues(n int) []object.Object {
+	values := make([]object.Object, n)
+	copy(values, vm.stack[vm.sp-n:vm.sp])
+	for i := vm.sp - n; i < vm.sp; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp -= n
+	return values
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+// invalid is the error a run stops with on invalid bytecode, it is not caught
+// by a try block
+func invalid(format string, a ...interface{}) *object.Error {
+	err := newError("%s: %s", ErrInvalidBytecode, fmt.Sprintf(format, a...))
+	err.Cause = ErrInvalidBytecode
+	return err
+}
@@ -0,0 +1,108 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/shanehowearth/interpreter/ast"
+	"github.com/shanehowearth/interpreter/code"
+	"github.com/shanehowearth/interpreter/compiler"
+	"github.com/shanehowearth/interpreter/evaluator"
+	"github.com/shanehowearth/interpreter/lexer"
+	"github.com/shanehowearth/interpreter/object"
+	"github.com/shanehowearth/interpreter/parser"
+)
+
+func parse(input string) *ast.Program {
+	return parser.New(lexer.New(input)).ParseProgram()
+}
+
+func compile(t testing.TB, input string) *compiler.Bytecode {
+	t.Helper()
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return comp.Bytecode()
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"let a = [1, 2]; a[0] += 5; a", "[6, 2]"},
+		{"let f = fn(x) { fn(y) { x + y } }; f(1)(2)", "3"},
+		{"let n = 0; for (let i = 0; i < 10; i += 1) { if (i == 5) { break }; n += i }; n", "10"},
+		{"let fs = []; for (x in [1, 2, 3]) { fs = push(fs, fn() { x }) }; fs[0]() + fs[2]()", "4"},
+		{`try { throw 1 } catch (e) { e["value"] + 1 }`, "2"},
+		{"let n = 0; let f = fn() { try { return 1 } finally { n = 2 } }; f() + n", "3"},
+	}
+	for _, tt := range tests {
+		result := New(compile(t, tt.input)).Run()
+		if result == nil || result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got=%v", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestRunReusesVM(t *testing.T) {
+	machine := New(compile(t, "let n = 0; for (x in [1, 2, 3, 4, 5]) { n += x }; n"), evaluator.WithStepBudget(10))
+	for i := 0; i < 3; i++ {
+		result, ok := machine.Run().(*object.Integer)
+		if !ok || result.Value != 15 {
+			t.Fatalf("run %d: expected 15, got=%v", i, result)
+		}
+	}
+}
+
+func TestRunInvalidBytecode(t *testing.T) {
+	tests := []struct {
+		instructions    []code.Instructions
+		constants       []object.Object
+		expectedMessage string
+	}{
+		{
+			[]code.Instructions{{255}},
+			nil,
+			"invalid bytecode: unknown opcode 255",
+		},
+		{
+			[]code.Instructions{code.Make(code.OpClosure, 0), code.Make(code.OpReturnValue)},
+			[]object.Object{&object.Integer{Value: 1}},
+			"invalid bytecode: constant 0 is not a function",
+		},
+	}
+	for _, tt := range tests {
+		bytecode := &compiler.Bytecode{Constants: tt.constants}
+		for _, ins := range tt.instructions {
+			bytecode.Instructions = append(bytecode.Instructions, ins...)
+		}
+		result, ok := New(bytecode).Run().(*object.Error)
+		if !ok {
+			t.Errorf("expected an error, got=%v", result)
+			continue
+		}
+		if result.Message != tt.expectedMessage || result.Cause != ErrInvalidBytecode {
+			t.Errorf("wrong error. expected=%q, got=%q (cause %v)", tt.expectedMessage, result.Message, result.Cause)
+		}
+	}
+}
+
+const fibonacci = `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(20)`
+
+func BenchmarkEvaluator(b *testing.B) {
+	program := parse(fibonacci)
+	for i := 0; i < b.N; i++ {
+		evaluator.Eval(program, object.NewEnvironment())
+	}
+}
+
+func BenchmarkVM(b *testing.B) {
+	bytecode := compile(b, fibonacci)
+	for i := 0; i < b.N; i++ {
+		New(bytecode).Run()
+	}
+}